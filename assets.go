@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -8,20 +10,37 @@ import (
 	"github.com/hashicorp/go-tfe"
 	"github.com/hashicorp/terraform-config-inspect/tfconfig"
 	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
-func (r *rover) generateAssets() error {
+func (r *rover) generateAssets(ctx context.Context) error {
+	// Load policies first to fail before running a plan
+	rules, err := LoadPolicies(r.PolicyFiles)
+	if err != nil {
+		return err
+	}
+
 	// Get Plan
-	err := r.getPlan()
+	err = r.getPlan(ctx)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Unable to parse Plan: %s", err))
 	}
 
+	r.updateMeta()
+
+	// Provider schemas describe attributes at /api/schema and mark sensitive ones
+	err = r.loadProviderSchemas(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Generate RSO, Map, Graph
 	err = r.GenerateResourceOverview()
 	if err != nil {
@@ -33,33 +52,55 @@ func (r *rover) generateAssets() error {
 		return err
 	}
 
+	err = r.GenerateProviderInventory()
+	if err != nil {
+		return err
+	}
+
+	err = r.GenerateModuleInventory()
+	if err != nil {
+		return err
+	}
+
 	err = r.GenerateGraph()
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	r.annotateSources()
 
-func (r *rover) getPlan() error {
-	tmpDir, err := ioutil.TempDir("", "rover")
+	err = r.GenerateGitMetadata(r.GitBase)
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(tmpDir)
 
-	tf, err := tfexec.NewTerraform(r.WorkingDir, r.TfPath)
+	r.EvaluatePolicies(rules)
+	r.GenerateLint()
+
+	return nil
+}
+
+func (r *rover) getPlan(ctx context.Context) error {
+	tmpDir, err := ioutil.TempDir("", "rover")
 	if err != nil {
 		return err
 	}
+	defer os.RemoveAll(tmpDir)
 
 	// If user provided path to plan file
 	if r.PlanPath != "" {
 		log.Println("Using provided plan...")
-		r.Plan, err = tf.ShowPlanFile(context.Background(), r.PlanPath)
+		tf, err := r.terraform(ctx)
+		if err != nil {
+			return err
+		}
+		planJson, err := tf.ShowPlanFileRaw(ctx, r.PlanPath)
 		if err != nil {
 			return errors.New(fmt.Sprintf("Unable to read Plan (%s): %s", r.PlanPath, err))
 		}
+		if err := r.parsePlanJSON(strings.NewReader(planJson)); err != nil {
+			return errors.New(fmt.Sprintf("Unable to read Plan (%s): %s", r.PlanPath, err))
+		}
 		return nil
 	}
 
@@ -73,12 +114,7 @@ func (r *rover) getPlan() error {
 		}
 		defer planJsonFile.Close()
 
-		planJson, err := ioutil.ReadAll(planJsonFile)
-		if err != nil {
-			return errors.New(fmt.Sprintf("Unable to read Plan (%s): %s", r.PlanJSONPath, err))
-		}
-
-		if err := json.Unmarshal(planJson, &r.Plan); err != nil {
+		if err := r.parsePlanJSON(bufio.NewReader(planJsonFile)); err != nil {
 			return errors.New(fmt.Sprintf("Unable to read Plan (%s): %s", r.PlanJSONPath, err))
 		}
 
@@ -107,13 +143,13 @@ func (r *rover) getPlan() error {
 		}
 
 		// Get TFC Workspace
-		ws, err := client.Workspaces.Read(context.Background(), r.TFCOrgName, r.TFCWorkspaceName)
+		ws, err := client.Workspaces.Read(ctx, r.TFCOrgName, r.TFCWorkspaceName)
 		if err != nil {
 			return errors.New(fmt.Sprintf("Unable to list workspace %s in %s organization. %s", r.TFCWorkspaceName, r.TFCOrgName, err))
 		}
 
 		// Retrieve all runs from specified TFC workspace
-		runs, err := client.Runs.List(context.Background(), ws.ID, tfe.RunListOptions{})
+		runs, err := client.Runs.List(ctx, ws.ID, tfe.RunListOptions{})
 		if err != nil {
 			return errors.New(fmt.Sprintf("Unable to retrieve plan from %s in %s organization. %s", r.TFCWorkspaceName, r.TFCOrgName, err))
 		}
@@ -133,7 +169,7 @@ func (r *rover) getPlan() error {
 		// If latest run is not actionable, rover will create new run
 		if r.TFCNewRun {
 			// Create new run in specified TFC workspace
-			newRun, err := client.Runs.Create(context.Background(), tfe.RunCreateOptions{
+			newRun, err := client.Runs.Create(ctx, tfe.RunCreateOptions{
 				Refresh:   &TRUE,
 				Workspace: ws,
 			})
@@ -147,7 +183,7 @@ func (r *rover) getPlan() error {
 
 			// Wait maximum of 5 mins
 			for i := 0; i < 30; i++ {
-				run, err := client.Runs.Read(context.Background(), newRun.ID)
+				run, err := client.Runs.Read(ctx, newRun.ID)
 				if err != nil {
 					return errors.New(fmt.Sprintf("Unable to retrieve run from %s in %s organization. %s", r.TFCWorkspaceName, r.TFCOrgName, err))
 				}
@@ -170,7 +206,7 @@ func (r *rover) getPlan() error {
 		}
 
 		// Get most recent plan file
-		planBytes, err := client.Plans.JSONOutput(context.Background(), planID)
+		planBytes, err := client.Plans.JSONOutput(ctx, planID)
 		if err != nil {
 			return errors.New(fmt.Sprintf("Unable to retrieve plan from %s in %s organization. %s", r.TFCWorkspaceName, r.TFCOrgName, err))
 		}
@@ -179,36 +215,27 @@ func (r *rover) getPlan() error {
 			return errors.New(fmt.Sprintf("Empty plan. Check run %s in %s in %s is not pending", run.ID, r.TFCWorkspaceName, r.TFCOrgName))
 		}
 
-		if err := json.Unmarshal(planBytes, &r.Plan); err != nil {
+		if err := r.parsePlanJSON(bytes.NewReader(planBytes)); err != nil {
 			return errors.New(fmt.Sprintf("Unable to parse plan (ID: %s) from %s in %s organization.: %s", planID, r.TFCWorkspaceName, r.TFCOrgName, err))
 		}
 
 		return nil
 	}
 
-	log.Println("Initializing Terraform...")
-
-	// Create TF Init options
-	var tfInitOptions []tfexec.InitOption
-	tfInitOptions = append(tfInitOptions, tfexec.Upgrade(true))
-
-	// Add *.tfbackend files
-	for _, tfBackendConfig := range r.TfBackendConfigs {
-		if tfBackendConfig != "" {
-			tfInitOptions = append(tfInitOptions, tfexec.BackendConfig(tfBackendConfig))
-		}
+	tf, err := r.terraform(ctx)
+	if err != nil {
+		return err
 	}
 
-	// tfInitOptions = append(tfInitOptions, tfexec.LockTimeout("60s"))
+	r.streamPlanOutput(tf, true)
 
-	err = tf.Init(context.Background(), tfInitOptions...)
-	if err != nil {
-		return errors.New(fmt.Sprintf("Unable to initialize Terraform Plan: %s", err))
+	if err := r.init(ctx, tf); err != nil {
+		return err
 	}
 
 	if r.WorkspaceName != "" {
 		log.Printf("Running in %s workspace...", r.WorkspaceName)
-		err = tf.WorkspaceSelect(context.Background(), r.WorkspaceName)
+		err = tf.WorkspaceSelect(ctx, r.WorkspaceName)
 		if err != nil {
 			return errors.New(fmt.Sprintf("Unable to select workspace (%s): %s", r.WorkspaceName, err))
 		}
@@ -235,16 +262,198 @@ func (r *rover) getPlan() error {
 		}
 	}
 
-	_, err = tf.Plan(context.Background(), tfPlanOptions...)
+	// Add targets, replacements and plan mode
+	for _, target := range r.Targets {
+		tfPlanOptions = append(tfPlanOptions, tfexec.Target(target))
+	}
+	for _, address := range r.Replace {
+		tfPlanOptions = append(tfPlanOptions, tfexec.Replace(address))
+	}
+	tfPlanOptions = append(tfPlanOptions, tfexec.Destroy(r.Destroy), tfexec.Refresh(r.Refresh), tfexec.Lock(r.Lock))
+	if r.Parallelism > 0 {
+		tfPlanOptions = append(tfPlanOptions, tfexec.Parallelism(r.Parallelism))
+	}
+
+	r.Meta.PlanMode = r.planMode()
+
+	// On cancellation, tfexec waits for terraform to finish and then returns the context error
+	if r.RefreshOnly {
+		// tfexec does not support -refresh-only yet
+		err = r.planRefreshOnly(ctx, planPath)
+	} else {
+		_, err = tf.Plan(ctx, tfPlanOptions...)
+	}
 	if err != nil {
 		return errors.New(fmt.Sprintf("Unable to run Plan: %s", err))
 	}
 
-	r.Plan, err = tf.ShowPlanFile(context.Background(), planPath)
+	r.streamPlanOutput(tf, false)
+
+	planJson, err := tf.ShowPlanFileRaw(ctx, planPath)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Unable to read Plan: %s", err))
 	}
 
+	if err := r.parsePlanJSON(strings.NewReader(planJson)); err != nil {
+		return errors.New(fmt.Sprintf("Unable to read Plan: %s", err))
+	}
+
+	return nil
+}
+
+// init runs terraform init, unless -skipInit is set or, with -initUpgrade=false, the working
+// directory is already initialized and no backend config or plugin directory is given
+func (r *rover) init(ctx context.Context, tf *tfexec.Terraform) error {
+	if r.SkipInit {
+		log.Println("Skipping terraform init...")
+		return nil
+	}
+
+	backendConfigs := []string{}
+	for _, tfBackendConfig := range r.TfBackendConfigs {
+		if tfBackendConfig != "" {
+			backendConfigs = append(backendConfigs, tfBackendConfig)
+		}
+	}
+
+	// Upgrades need init, otherwise the installed versions can be reused
+	if !r.InitUpgrade && len(backendConfigs) == 0 && len(r.PluginDirs) == 0 {
+		ok, missing := initialized(r.WorkingDir)
+		if ok {
+			log.Println("Using initialized .terraform directory...")
+			return nil
+		}
+		log.Printf("Working directory is not initialized: %s", missing)
+	}
+
+	log.Println("Initializing Terraform...")
+
+	// Create TF Init options
+	var tfInitOptions []tfexec.InitOption
+	tfInitOptions = append(tfInitOptions, tfexec.Upgrade(r.InitUpgrade))
+
+	// Add *.tfbackend files
+	for _, tfBackendConfig := range backendConfigs {
+		tfInitOptions = append(tfInitOptions, tfexec.BackendConfig(tfBackendConfig))
+	}
+
+	// Install providers only from these directories
+	for _, pluginDir := range r.PluginDirs {
+		tfInitOptions = append(tfInitOptions, tfexec.PluginDir(pluginDir))
+	}
+
+	// tfInitOptions = append(tfInitOptions, tfexec.LockTimeout("60s"))
+
+	err := tf.Init(ctx, tfInitOptions...)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Unable to initialize Terraform Plan: %s", err))
+	}
+
+	return nil
+}
+
+// resourceChangeJSON is a resource change with the fields that tfjson does not expose yet,
+// its change field takes precedence over the one of the embedded tfjson.ResourceChange
+type resourceChangeJSON struct {
+	tfjson.ResourceChange
+	Change *changeJSON `json:"change"`
+}
+
+type changeJSON struct {
+	tfjson.Change
+	ReplacePaths [][]interface{} `json:"replace_paths,omitempty"`
+}
+
+// parsePlanJSON decodes the plan and the fields missing from tfjson.Plan while reading it.
+// Each top-level value is decoded into the plan directly, and resource changes one at a time,
+// so the plan JSON is never held in memory as a whole next to the decoded plan.
+func (r *rover) parsePlanJSON(reader io.Reader) error {
+	dec := json.NewDecoder(reader)
+	plan := &tfjson.Plan{}
+	r.ReplacePaths = make(map[string][][]interface{})
+
+	fields := map[string]interface{}{
+		"format_version":    &plan.FormatVersion,
+		"terraform_version": &plan.TerraformVersion,
+		"variables":         &plan.Variables,
+		"planned_values":    &plan.PlannedValues,
+		"output_changes":    &plan.OutputChanges,
+		"prior_state":       &plan.PriorState,
+		"configuration":     &plan.Config,
+	}
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+
+		if key == "resource_changes" {
+			if err := r.decodeResourceChanges(dec, plan); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Unknown fields, e.g. of newer Terraform and OpenTofu versions, are skipped
+		var field interface{} = &json.RawMessage{}
+		if f, ok := fields[key]; ok {
+			field = f
+		}
+		if err := dec.Decode(field); err != nil {
+			return fmt.Errorf("%s: %s", key, err)
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+
+	// OpenTofu and newer Terraform versions may write format versions unknown to tfjson
+	if err := plan.Validate(); err != nil {
+		if plan.FormatVersion == "" {
+			return err
+		}
+		log.Printf("Continuing with unsupported plan: %s", err)
+	}
+
+	r.Plan = plan
+	return nil
+}
+
+// decodeResourceChanges decodes the resource_changes array element by element
+func (r *rover) decodeResourceChanges(dec *json.Decoder, plan *tfjson.Plan) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for dec.More() {
+		rc := &resourceChangeJSON{}
+		if err := dec.Decode(rc); err != nil {
+			return fmt.Errorf("resource_changes: %s", err)
+		}
+
+		if rc.Change != nil {
+			rc.ResourceChange.Change = &rc.Change.Change
+			if len(rc.Change.ReplacePaths) > 0 {
+				r.ReplacePaths[rc.Address] = rc.Change.ReplacePaths
+			}
+		}
+		plan.ResourceChanges = append(plan.ResourceChanges, &rc.ResourceChange)
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("unexpected %v in plan JSON, expected %v", token, delim)
+	}
 	return nil
 }
 
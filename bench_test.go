@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// syntheticPlan builds a plan JSON with n resource changes in modules of 100 resources,
+// each resource referencing the one before it
+func syntheticPlan(tb testing.TB, n int) []byte {
+	tb.Helper()
+	plan := &tfjson.Plan{
+		FormatVersion:    "0.2",
+		TerraformVersion: "1.1.0",
+		PlannedValues:    &tfjson.StateValues{RootModule: &tfjson.StateModule{}},
+		Config: &tfjson.Config{
+			RootModule: &tfjson.ConfigModule{ModuleCalls: map[string]*tfjson.ModuleCall{}},
+		},
+	}
+
+	for m := 0; m*100 < n; m++ {
+		name := fmt.Sprintf("m%d", m)
+		module := fmt.Sprintf("module.%s", name)
+		state := &tfjson.StateModule{Address: module}
+		config := &tfjson.ConfigModule{}
+
+		for i := m * 100; i < n && i < (m+1)*100; i++ {
+			resource := fmt.Sprintf("null_resource.r%d", i)
+			address := fmt.Sprintf("%s.%s", module, resource)
+			actions := tfjson.Actions{tfjson.ActionCreate}
+			if i%10 == 0 {
+				actions = tfjson.Actions{tfjson.ActionDelete, tfjson.ActionCreate}
+			}
+
+			state.Resources = append(state.Resources, &tfjson.StateResource{
+				Address:      address,
+				Mode:         tfjson.ManagedResourceMode,
+				Type:         "null_resource",
+				Name:         fmt.Sprintf("r%d", i),
+				ProviderName: "registry.terraform.io/hashicorp/null",
+			})
+			plan.ResourceChanges = append(plan.ResourceChanges, &tfjson.ResourceChange{
+				Address:       address,
+				ModuleAddress: module,
+				Mode:          tfjson.ManagedResourceMode,
+				Type:          "null_resource",
+				Name:          fmt.Sprintf("r%d", i),
+				ProviderName:  "registry.terraform.io/hashicorp/null",
+				Change: &tfjson.Change{
+					Actions: actions,
+					After:   map[string]interface{}{"triggers": map[string]interface{}{"index": fmt.Sprint(i)}},
+				},
+			})
+
+			expressions := map[string]*tfjson.Expression{}
+			if i > m*100 {
+				previous := fmt.Sprintf("null_resource.r%d", i-1)
+				expressions["triggers"] = &tfjson.Expression{ExpressionData: &tfjson.ExpressionData{
+					References: []string{previous + ".id", previous},
+				}}
+			}
+			config.Resources = append(config.Resources, &tfjson.ConfigResource{
+				Address:           resource,
+				Mode:              tfjson.ManagedResourceMode,
+				Type:              "null_resource",
+				Name:              fmt.Sprintf("r%d", i),
+				ProviderConfigKey: name + ":null",
+				Expressions:       expressions,
+			})
+		}
+
+		plan.PlannedValues.RootModule.ChildModules = append(plan.PlannedValues.RootModule.ChildModules, state)
+		plan.Config.RootModule.ModuleCalls[name] = &tfjson.ModuleCall{Source: "./" + name, Module: config}
+	}
+
+	content, err := json.Marshal(plan)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return content
+}
+
+func benchmarkGenerate(b *testing.B, n int) {
+	plan := syntheticPlan(b, n)
+	workingDir := b.TempDir()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(plan)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := &rover{WorkingDir: workingDir}
+		if err := r.parsePlanJSON(bytes.NewReader(plan)); err != nil {
+			b.Fatal(err)
+		}
+		if err := r.GenerateResourceOverview(); err != nil {
+			b.Fatal(err)
+		}
+		if err := r.GenerateMap(); err != nil {
+			b.Fatal(err)
+		}
+		if err := r.GenerateGraph(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenerate1k(b *testing.B)  { benchmarkGenerate(b, 1000) }
+func BenchmarkGenerate10k(b *testing.B) { benchmarkGenerate(b, 10000) }
+func BenchmarkGenerate50k(b *testing.B) { benchmarkGenerate(b, 50000) }
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-exec/tfexec"
+)
+
+type Flavor string
+
+const (
+	FlavorTerraform Flavor = "terraform"
+	FlavorOpenTofu  Flavor = "opentofu"
+)
+
+type PlanMode string
+
+const (
+	PlanModeNormal      PlanMode = "normal"
+	PlanModeDestroy     PlanMode = "destroy"
+	PlanModeRefreshOnly PlanMode = "refresh-only"
+)
+
+// Legacy default of -tfPath, used if no binary is found otherwise
+const defaultTfPath = "/usr/local/bin/terraform"
+
+// Meta describes the binary and plan Rover ran with, served at /api/meta
+type Meta struct {
+	RoverVersion string `json:"rover_version,omitempty"`
+	// Flavor and version of the binary, or of the plan if no binary was run
+	Flavor  Flavor `json:"flavor,omitempty"`
+	Version string `json:"version,omitempty"`
+	Path    string `json:"path,omitempty"`
+	// Mode of the plan Rover generated, empty for provided plans
+	PlanMode PlanMode `json:"plan_mode,omitempty"`
+	// Versions recorded in the plan JSON
+	PlanFormatVersion    string `json:"plan_format_version,omitempty"`
+	PlanTerraformVersion string `json:"plan_terraform_version,omitempty"`
+}
+
+// findTfBinary resolves the terraform or tofu binary: -tfPath, TF_PATH, TOFU_PATH,
+// terraform or tofu in PATH, then the legacy default path
+func findTfBinary(tfPath string) (string, error) {
+	if tfPath != "" {
+		return tfPath, nil
+	}
+
+	for _, env := range []string{"TF_PATH", "TOFU_PATH"} {
+		if path := os.Getenv(env); path != "" {
+			return path, nil
+		}
+	}
+
+	for _, name := range []string{"terraform", "tofu"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+
+	if _, err := os.Stat(defaultTfPath); err == nil {
+		return defaultTfPath, nil
+	}
+
+	return "", errors.New("No terraform or tofu binary found in PATH, set -tfPath, TF_PATH or TOFU_PATH")
+}
+
+// binaryVersion runs "<binary> version" and reads the flavor and version from its first line
+func binaryVersion(ctx context.Context, path string) (Flavor, *version.Version, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, "version")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseVersionOutput(stdout.String())
+}
+
+// parseVersionOutput parses the plain version output, e.g. "OpenTofu v1.6.0" or "Terraform v1.5.7"
+func parseVersionOutput(out string) (Flavor, *version.Version, error) {
+	line, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return "", nil, fmt.Errorf("unexpected version output %q", line)
+	}
+
+	flavor := FlavorTerraform
+	if fields[0] == "OpenTofu" {
+		flavor = FlavorOpenTofu
+	}
+	v, err := version.NewVersion(strings.TrimPrefix(fields[1], "v"))
+	if err != nil {
+		return "", nil, fmt.Errorf("unexpected version output %q: %s", line, err)
+	}
+	return flavor, v, nil
+}
+
+// terraform creates the tfexec instance of the detected binary. The binary, its flavor and
+// version are detected on the first call and cached on r.Meta for later calls.
+func (r *rover) terraform(ctx context.Context) (*tfexec.Terraform, error) {
+	if r.Meta.Path == "" {
+		if err := r.detectBinary(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	tf, err := tfexec.NewTerraform(r.WorkingDir, r.Meta.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stream errors to /api/progress, stdout only while planning (see streamPlanOutput)
+	if r.progress != nil {
+		tf.SetStderr(r.progress)
+	}
+
+	return tf, nil
+}
+
+// detectBinary records the path, flavor and version of the binary in r.Meta.
+// tf.Version is not used: it runs "version -json", whose output is the same for
+// terraform and tofu, so only the plain version output tells the flavor apart.
+func (r *rover) detectBinary(ctx context.Context) error {
+	path, err := findTfBinary(r.TfPath)
+	if err != nil {
+		return err
+	}
+
+	flavor, version, err := binaryVersion(ctx, path)
+	if err != nil {
+		return fmt.Errorf("Unable to run %s: %s", path, err)
+	}
+
+	r.Meta.Path = path
+	r.Meta.Flavor = flavor
+	r.Meta.Version = version.String()
+
+	log.Printf("Using %s v%s (%s)", r.Meta.Flavor, r.Meta.Version, path)
+
+	return nil
+}
+
+// streamPlanOutput streams the stdout of init and plan to /api/progress,
+// or stops it so JSON output of show is not streamed
+func (r *rover) streamPlanOutput(tf *tfexec.Terraform, stream bool) {
+	if r.progress != nil && stream {
+		tf.SetStdout(r.progress)
+	} else {
+		tf.SetStdout(nil)
+	}
+}
+
+// planMode returns the mode of the plan generated with -destroy or -refreshOnly
+func (r *rover) planMode() PlanMode {
+	switch {
+	case r.Destroy:
+		return PlanModeDestroy
+	case r.RefreshOnly:
+		return PlanModeRefreshOnly
+	}
+	return PlanModeNormal
+}
+
+// planRefreshOnly runs plan -refresh-only with the vars and options of tf.Plan
+func (r *rover) planRefreshOnly(ctx context.Context, planPath string) error {
+	args := []string{"plan", "-no-color", "-input=false", "-refresh-only", "-out=" + planPath}
+	args = append(args, fmt.Sprintf("-lock=%t", r.Lock))
+	if r.Parallelism > 0 {
+		args = append(args, fmt.Sprintf("-parallelism=%d", r.Parallelism))
+	}
+	for _, target := range r.Targets {
+		args = append(args, "-target="+target)
+	}
+	for _, tfVarsFile := range r.TfVarsFiles {
+		if tfVarsFile != "" {
+			args = append(args, "-var-file="+tfVarsFile)
+		}
+	}
+	for _, tfVar := range r.TfVars {
+		if tfVar != "" {
+			args = append(args, "-var", tfVar)
+		}
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.Meta.Path, args...)
+	cmd.Dir = r.WorkingDir
+	cmd.Env = append(os.Environ(), "TF_IN_AUTOMATION=1")
+	interruptOnCancel(cmd)
+	cmd.Stderr = &stderr
+	if r.progress != nil {
+		cmd.Stdout = r.progress
+		cmd.Stderr = io.MultiWriter(&stderr, r.progress)
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// planFlavor guesses the flavor of a plan from its provider registry, e.g. registry.opentofu.org
+func (r *rover) planFlavor() Flavor {
+	for _, rc := range r.Plan.ResourceChanges {
+		if strings.HasPrefix(rc.ProviderName, "registry.opentofu.org/") {
+			return FlavorOpenTofu
+		}
+		if strings.HasPrefix(rc.ProviderName, "registry.terraform.io/") {
+			return FlavorTerraform
+		}
+	}
+	return ""
+}
+
+// updateMeta adds the versions of the plan, and its flavor if no binary was run
+func (r *rover) updateMeta() {
+	if r.Plan == nil {
+		return
+	}
+	r.Meta.PlanFormatVersion = r.Plan.FormatVersion
+	r.Meta.PlanTerraformVersion = r.Plan.TerraformVersion
+	if r.Meta.Flavor == "" {
+		r.Meta.Flavor = r.planFlavor()
+		r.Meta.Version = r.Plan.TerraformVersion
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindTfBinary(t *testing.T) {
+	stubDir, _ := filepath.Abs("testdata/stub-tofu")
+	stub := filepath.Join(stubDir, "tofu")
+
+	t.Setenv("TF_PATH", "")
+	t.Setenv("TOFU_PATH", "")
+	t.Setenv("PATH", stubDir)
+
+	if got, err := findTfBinary("/opt/terraform"); err != nil || got != "/opt/terraform" {
+		t.Errorf("findTfBinary(-tfPath) = %q, %v, want /opt/terraform", got, err)
+	}
+
+	// terraform is not in PATH, tofu is
+	if got, err := findTfBinary(""); err != nil || got != stub {
+		t.Errorf("findTfBinary from PATH = %q, %v, want %q", got, err, stub)
+	}
+
+	t.Setenv("TOFU_PATH", "/opt/tofu")
+	if got, _ := findTfBinary(""); got != "/opt/tofu" {
+		t.Errorf("findTfBinary with TOFU_PATH = %q, want /opt/tofu", got)
+	}
+
+	// TF_PATH takes precedence over TOFU_PATH
+	t.Setenv("TF_PATH", "/opt/terraform")
+	if got, _ := findTfBinary(""); got != "/opt/terraform" {
+		t.Errorf("findTfBinary with TF_PATH = %q, want /opt/terraform", got)
+	}
+
+	t.Setenv("TF_PATH", "")
+	t.Setenv("TOFU_PATH", "")
+	t.Setenv("PATH", t.TempDir())
+	if _, err := os.Stat(defaultTfPath); err == nil {
+		t.Skipf("%s exists", defaultTfPath)
+	}
+	if got, err := findTfBinary(""); err == nil {
+		t.Errorf("findTfBinary without binary = %q, want an error", got)
+	}
+}
+
+func TestParseVersionOutput(t *testing.T) {
+	tests := []struct {
+		out     string
+		flavor  Flavor
+		version string
+	}{
+		{"Terraform v1.5.7\non linux_amd64\n", FlavorTerraform, "1.5.7"},
+		{"OpenTofu v1.6.0\non darwin_arm64\n", FlavorOpenTofu, "1.6.0"},
+		{"Terraform v1.8.0-beta1\non linux_amd64\n", FlavorTerraform, "1.8.0-beta1"},
+		{"Terraform v0.12.31\n\nYour version of Terraform is out of date!", FlavorTerraform, "0.12.31"},
+	}
+
+	for _, tt := range tests {
+		flavor, v, err := parseVersionOutput(tt.out)
+		if err != nil {
+			t.Errorf("parseVersionOutput(%q): %s", tt.out, err)
+			continue
+		}
+		if flavor != tt.flavor || v.String() != tt.version {
+			t.Errorf("parseVersionOutput(%q) = %s %s, want %s %s", tt.out, flavor, v, tt.flavor, tt.version)
+		}
+	}
+
+	if _, _, err := parseVersionOutput("garbage"); err == nil {
+		t.Error("parseVersionOutput(garbage): want an error")
+	}
+}
+
+func TestTerraformStubTofu(t *testing.T) {
+	stub, _ := filepath.Abs("testdata/stub-tofu/tofu")
+
+	r := &rover{WorkingDir: t.TempDir(), TfPath: stub}
+	if _, err := r.terraform(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.Meta.Flavor != FlavorOpenTofu || r.Meta.Version != "1.6.2" || r.Meta.Path != stub {
+		t.Errorf("Meta = %+v, want opentofu 1.6.2 at %s", r.Meta, stub)
+	}
+}
+
+func TestTerraformDetectsOnce(t *testing.T) {
+	content, err := os.ReadFile("testdata/stub-tofu/tofu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := filepath.Join(t.TempDir(), "tofu")
+	if err := os.WriteFile(stub, content, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &rover{WorkingDir: t.TempDir(), TfPath: stub}
+	if _, err := r.terraform(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Later calls must not run version again
+	if err := os.WriteFile(stub, []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.terraform(context.Background()); err != nil {
+		t.Errorf("second terraform(): %s", err)
+	}
+	if r.Meta.Flavor != FlavorOpenTofu || r.Meta.Version != "1.6.2" {
+		t.Errorf("Meta = %+v, want opentofu 1.6.2", r.Meta)
+	}
+}
@@ -3,10 +3,15 @@ package config
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"time"
+	"unicode"
 )
 
 type arrayFlags []string
@@ -26,34 +31,90 @@ func (i *arrayFlags) Set(value string) error {
 
 // Config speichert die gesamten Flags und Umgebungsvariablen
 type Config struct {
-	TfPath           string
-	WorkingDir       string
-	Name             string
-	ZipFileName      string
-	IPPort           string
-	PlanPath         string
-	PlanJSONPath     string
-	WorkspaceName    string
-	TFCOrgName       string
-	TFCWorkspaceName string
-	Standalone       bool
-	ShowSensitive    bool
-	GenImage         bool
-	GetVersion       string
-	TFCNewRun        bool
-	TfVarsFiles      arrayFlags
-	TfVars           arrayFlags
-	TfBackendConfigs arrayFlags
-	Version          string // Konstante für Version
+	TfPath             string
+	WorkingDir         string
+	Name               string
+	ZipFileName        string
+	IPPort             string
+	PlanPath           string
+	PlanJSONPath       string
+	WorkspaceName      string
+	TFCOrgName         string
+	TFCWorkspaceName   string
+	Standalone         bool
+	ShowSensitive      bool
+	ProviderSchema     bool
+	ProviderSchemaPath string
+	GenImage           bool
+	ImagePath          string
+	ImageFormat        string // svg, png oder pdf, sonst aus der Endung von ImagePath
+	ImageViewport      string // Breite x Höhe, z.B. 1920x1080
+	ImageWidth         int64
+	ImageHeight        int64
+	ImageScale         float64
+	ImageTimeout       time.Duration
+	GraphFilters       arrayFlags // <Parameter>=<Wert> wie bei /api/graph, z.B. changedOnly=true
+	ImagePerModule     bool
+	GetVersion         string
+	TFCNewRun          bool
+	TfVarsFiles        arrayFlags
+	TfVars             arrayFlags
+	TfBackendConfigs   arrayFlags
+	Targets            arrayFlags
+	Replace            arrayFlags
+	Destroy            bool
+	Refresh            bool
+	RefreshOnly        bool
+	Lock               bool
+	Parallelism        int // 0 übernimmt den Standardwert von Terraform
+	SkipInit           bool
+	InitUpgrade        bool
+	PluginDirs         arrayFlags
+	ConfigFile         string
+	Profile            string
+	Command            string   // Unterbefehl, z.B. "summary"
+	Args               []string // Positionsargumente nach den Flags
+	Format             string
+	Out                string
+	DetailedExitCode   bool
+	FailOnDelete       arrayFlags
+	NoColor            bool
+	ReportTemplate     string
+	PolicyFiles        arrayFlags
+	Roots              arrayFlags
+	TerragruntDir      string
+	TerragruntPath     string
+	TerragruntPlanJSON string // Name der Plan-JSON-Datei in jeder Unit
+	FailOnSeverity     string
+	SarifOut           string
+	JUnitOut           string
+	GitBase            string // Basis-Ref für den Vergleich mit git, z.B. main
+	EditorURL          string // Vorlage für Links zum Quelltext, z.B. vscode://file/{path}:{line}
+	Version            string // Konstante für Version
 }
 
-// Lade Konfiguration aus Flags
-func LoadConfig() *Config {
+// Commands enthält die unterstützten Unterbefehle ("" startet den Server)
+var Commands = []string{"summary", "report", "config-diff", "modules"}
+
+// Lade Konfiguration aus Flags, ROVER_*-Umgebungsvariablen und Konfigurationsdatei
+// Vorrang: Flags > Umgebungsvariablen > Datei > Standardwerte
+func LoadConfig() (*Config, error) {
 	config := &Config{}
 
 	log.Println("Loading configuration...")
+
+	// Unterbefehl vor den Flags, z.B. "rover summary -planJSONPath plan.json"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		config.Command = args[0]
+		args = args[1:]
+	}
+	if err := validateCommand(config.Command); err != nil {
+		return nil, err
+	}
+
 	// Definiere Flags
-	flag.StringVar(&config.TfPath, "tfPath", "/usr/local/bin/terraform", "Path to Terraform binary")
+	flag.StringVar(&config.TfPath, "tfPath", "", "Path to Terraform or OpenTofu binary (default: TF_PATH, TOFU_PATH, terraform or tofu in PATH)")
 	flag.StringVar(&config.WorkingDir, "workingDir", ".", "Path to Terraform configuration")
 	flag.StringVar(&config.Name, "name", "rover", "Configuration name")
 	flag.StringVar(&config.ZipFileName, "zipFileName", "rover.zip", "Standalone zip file name")
@@ -66,19 +127,114 @@ func LoadConfig() *Config {
 	flag.StringVar(&config.GetVersion, "version", "0.3.3", "Get current version")
 	flag.BoolVar(&config.Standalone, "standalone", false, "Generate standalone HTML files")
 	flag.BoolVar(&config.ShowSensitive, "showSensitive", false, "Display sensitive values")
+	flag.BoolVar(&config.ProviderSchema, "providerSchema", false, "Run terraform providers schema -json to describe attributes at /api/schema and redact sensitive attributes")
+	flag.StringVar(&config.ProviderSchemaPath, "providerSchemaPath", "", "Path to the output of terraform providers schema -json, instead of running it")
 	flag.BoolVar(&config.TFCNewRun, "tfcNewRun", false, "Create new Terraform Cloud run")
 	flag.BoolVar(&config.GenImage, "genImage", false, "Generate graph image")
+	flag.StringVar(&config.ImagePath, "imagePath", "", "Output file of -genImage (default: rover.<imageFormat>)")
+	flag.StringVar(&config.ImageFormat, "imageFormat", "", "Image format: svg, png, pdf (default: extension of imagePath, or svg)")
+	flag.StringVar(&config.ImageViewport, "imageViewport", "1920x1080", "Browser viewport of -genImage as WIDTHxHEIGHT")
+	flag.Float64Var(&config.ImageScale, "imageScale", 1, "Scale of the image")
+	flag.DurationVar(&config.ImageTimeout, "imageTimeout", 60*time.Second, "Timeout of the generation of each image")
+	flag.BoolVar(&config.ImagePerModule, "imagePerModule", false, "Generate one image per top-level module, named <imagePath>-<module>.<imageFormat>")
+	flag.StringVar(&config.ConfigFile, "config", "", "Path to config file (default: .rover.yaml or .rover.hcl in workingDir)")
+	flag.StringVar(&config.Profile, "profile", "", "Named profile from config file")
+	flag.StringVar(&config.Format, "format", "", "Output format of the command (summary: text, markdown, json; report: markdown, html; config-diff: json; modules: text, json)")
+	flag.StringVar(&config.Out, "out", "", "Output file of the command (default: stdout)")
+	flag.BoolVar(&config.DetailedExitCode, "detailedExitCode", false, "summary: exit with 2 if the plan contains changes")
+	flag.StringVar(&config.ReportTemplate, "reportTemplate", "", "report: Go template replacing the default markdown/html template")
+	flag.BoolVar(&config.NoColor, "noColor", os.Getenv("NO_COLOR") != "", "Disable colored output")
 
 	var tfVarsFiles, tfVars, tfBackendConfigs arrayFlags
 	flag.Var(&tfVarsFiles, "tfVarsFile", "Path to *.tfvars files")
 	flag.Var(&tfVars, "tfVar", "Terraform variable (key=value)")
 	flag.Var(&tfBackendConfigs, "tfBackendConfig", "Path to *.tfbackend files")
-	flag.Parse()
+
+	var targets, replace arrayFlags
+	flag.Var(&targets, "target", "Limit the plan to this resource address (repeatable)")
+	flag.Var(&replace, "replace", "Plan to replace the resource at this address (repeatable)")
+	flag.BoolVar(&config.Destroy, "destroy", false, "Create a destroy plan")
+	flag.BoolVar(&config.Refresh, "refresh", true, "Refresh the state before planning")
+	flag.BoolVar(&config.RefreshOnly, "refreshOnly", false, "Create a refresh-only plan")
+	flag.BoolVar(&config.Lock, "lock", true, "Lock the state while planning")
+	flag.IntVar(&config.Parallelism, "parallelism", 0, "Limit the number of concurrent operations while planning (default: Terraform default)")
+
+	var pluginDirs arrayFlags
+	flag.BoolVar(&config.SkipInit, "skipInit", false, "Do not run terraform init")
+	flag.BoolVar(&config.InitUpgrade, "initUpgrade", true, "Upgrade modules and providers during terraform init")
+	flag.Var(&pluginDirs, "pluginDir", "Install providers only from this directory during terraform init (repeatable)")
+
+	flag.StringVar(&config.FailOnSeverity, "failOnSeverity", "", "Exit with 4 if findings reach the severity (info, low, medium, high, critical)")
+
+	flag.StringVar(&config.SarifOut, "sarifOut", "", "Write findings as SARIF to this file")
+	flag.StringVar(&config.JUnitOut, "junitOut", "", "Write findings as JUnit XML to this file")
+	flag.StringVar(&config.TerragruntDir, "terragrunt", "", "Combine all Terragrunt units (terragrunt.hcl) below this directory into one graph")
+	flag.StringVar(&config.TerragruntPath, "terragruntPath", "terragrunt", "Path to Terragrunt binary")
+	flag.StringVar(&config.TerragruntPlanJSON, "terragruntPlanJSON", "plan.json", "Plan JSON file in each Terragrunt unit, terragrunt plan runs if it does not exist")
+	flag.StringVar(&config.GitBase, "gitBase", "", "Annotate nodes with changedInBranch, lastCommit and lastAuthor from the local git repository, comparing with this ref (e.g. main)")
+	flag.StringVar(&config.EditorURL, "editorURL", "", "Link template for node sources with {path}, {relpath}, {line}, {remote}, {commit}, or a preset: vscode, idea, sublime, github, gitlab, bitbucket")
+
+	var failOnDelete, policyFiles, roots, graphFilters, imageFilters arrayFlags
+	flag.Var(&graphFilters, "graphFilter", "Show only part of the graph with -genImage and -standalone, like the /api/graph query: module, type, provider, action, address, changedOnly, depth, neighbors or collapseDepth=<value> (repeatable)")
+	flag.Var(&imageFilters, "imageFilter", "Deprecated, use -graphFilter: changes-only or module=<name> (repeatable)")
+	flag.Var(&policyFiles, "policy", "Path to policy rules (*.yaml or *.hcl)")
+	flag.Var(&roots, "root", "Root module directory or plan JSON file to combine into one graph (repeatable)")
+	flag.Var(&failOnDelete, "failOnDelete", "summary: exit with 3 if a deleted or replaced address matches the glob pattern")
+
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return nil, err
+	}
+	config.Args = flag.Args()
+
+	if err := applyEnvAndFile(config); err != nil {
+		return nil, err
+	}
 
 	// Lade Flags in Config
 	config.TfVarsFiles = tfVarsFiles
 	config.TfVars = tfVars
 	config.TfBackendConfigs = tfBackendConfigs
+	config.Targets = targets
+	config.Replace = replace
+	config.PluginDirs = pluginDirs
+	config.FailOnDelete = failOnDelete
+	config.PolicyFiles = policyFiles
+	config.Roots = roots
+	config.GraphFilters = graphFilters
+	// -imageFilter wird in -graphFilter übernommen
+	for _, filter := range imageFilters {
+		if filter == "changes-only" {
+			filter = "changedOnly=true"
+		} else if !strings.HasPrefix(filter, "module=") {
+			return nil, fmt.Errorf("invalid value %q for imageFilter (available: changes-only, module=<name>)", filter)
+		}
+		config.GraphFilters = append(config.GraphFilters, filter)
+	}
+
+	if err := parseImageOptions(config); err != nil {
+		return nil, err
+	}
+
+	if err := checkGraphFilters(config); err != nil {
+		return nil, err
+	}
+
+	switch config.FailOnSeverity {
+	case "", "info", "low", "medium", "high", "critical":
+	default:
+		return nil, fmt.Errorf("invalid value %q for failOnSeverity (available: info, low, medium, high, critical)", config.FailOnSeverity)
+	}
+
+	// Plan-Modi schließen sich gegenseitig aus
+	if config.Destroy && config.RefreshOnly {
+		return nil, errors.New("destroy and refreshOnly cannot be combined")
+	}
+	if config.RefreshOnly && (!config.Refresh || len(config.Replace) > 0) {
+		return nil, errors.New("refreshOnly cannot be combined with refresh=false or replace")
+	}
+	if config.Parallelism < 0 {
+		return nil, fmt.Errorf("invalid value %d for parallelism", config.Parallelism)
+	}
 
 	path, err := os.Getwd()
 	if err != nil {
@@ -116,5 +272,197 @@ func LoadConfig() *Config {
 		log.Printf("Rover v%s\n", config.Version)
 	}
 
-	return config
+	return config, nil
+}
+
+// Parameter von /api/graph, die mit -graphFilter gesetzt werden können
+var graphFilterKeys = []string{"module", "type", "provider", "action", "address", "changedOnly", "depth", "neighbors", "collapseDepth"}
+
+// checkGraphFilters prüft die Form <Parameter>=<Wert>, die Werte prüft ParseGraphFilter
+func checkGraphFilters(config *Config) error {
+	for _, filter := range config.GraphFilters {
+		key, _, ok := strings.Cut(filter, "=")
+		if !ok || !slices.Contains(graphFilterKeys, key) {
+			return fmt.Errorf("invalid value %q for graphFilter (available: %s=<value>)", filter, strings.Join(graphFilterKeys, ", "))
+		}
+	}
+
+	return nil
+}
+
+// parseImageOptions prüft die Optionen von -genImage und leitet Format, Pfad und Viewport ab
+func parseImageOptions(config *Config) error {
+	if config.ImageFormat == "" {
+		config.ImageFormat = "svg"
+		if ext := strings.TrimPrefix(filepath.Ext(config.ImagePath), "."); ext != "" {
+			config.ImageFormat = strings.ToLower(ext)
+		}
+	}
+	switch config.ImageFormat {
+	case "svg", "png", "pdf":
+	default:
+		return fmt.Errorf("invalid value %q for imageFormat (available: svg, png, pdf)", config.ImageFormat)
+	}
+
+	if config.ImagePath == "" {
+		config.ImagePath = "rover." + config.ImageFormat
+	}
+
+	if _, err := fmt.Sscanf(config.ImageViewport, "%dx%d", &config.ImageWidth, &config.ImageHeight); err != nil || config.ImageWidth <= 0 || config.ImageHeight <= 0 {
+		return fmt.Errorf("invalid value %q for imageViewport (expected WIDTHxHEIGHT, e.g. 1920x1080)", config.ImageViewport)
+	}
+
+	if config.ImageScale <= 0 {
+		return fmt.Errorf("invalid value %g for imageScale", config.ImageScale)
+	}
+
+	return nil
+}
+
+func validateCommand(command string) error {
+	if command == "" {
+		return nil
+	}
+	for _, c := range Commands {
+		if c == command {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown command %q (available: %s)", command, strings.Join(Commands, ", "))
+}
+
+// applyEnvAndFile setzt alle nicht explizit gesetzten Flags aus ROVER_*-Umgebungsvariablen
+// oder, falls dort nicht vorhanden, aus der Konfigurationsdatei
+func applyEnvAndFile(config *Config) error {
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	// workingDir, config und profile bestimmen, welche Datei gelesen wird
+	for _, name := range []string{"workingDir", "config", "profile"} {
+		if err := setFromEnv(name, explicit); err != nil {
+			return err
+		}
+	}
+
+	configFile := config.ConfigFile
+	if configFile == "" {
+		configFile = findConfigFile(config.WorkingDir)
+	} else if !filepath.IsAbs(configFile) {
+		if _, err := os.Stat(configFile); err != nil {
+			configFile = filepath.Join(config.WorkingDir, configFile)
+		}
+	}
+
+	fileValues := map[string][]string{}
+	if configFile != "" {
+		fc, err := loadConfigFile(configFile)
+		if err != nil {
+			return err
+		}
+
+		// Profil aus der Datei, falls nicht über Flag oder Umgebung gesetzt
+		if p, ok := fc.Values["profile"]; ok && config.Profile == "" && len(p) == 1 {
+			config.Profile = p[0]
+		}
+		delete(fc.Values, "profile")
+
+		fileValues, err = fc.values(config.Profile)
+		if err != nil {
+			return err
+		}
+		log.Printf("Using config file %s", configFile)
+	} else if config.Profile != "" {
+		return fmt.Errorf("profile %q requested, but no config file found", config.Profile)
+	}
+
+	// Unbekannte Schlüssel in der Datei ablehnen
+	keys := make([]string, 0, len(fileValues))
+	for key := range fileValues {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "config" || key == "profile" || flag.Lookup(key) == nil {
+			return fmt.Errorf("%s: unknown key %q", configFile, key)
+		}
+		// Die Datei wird erst im workingDir gesucht, daher kann sie ihn nicht festlegen
+		if key == "workingDir" {
+			return fmt.Errorf("%s: workingDir cannot be set in the config file", configFile)
+		}
+	}
+
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] || f.Name == "workingDir" || f.Name == "config" || f.Name == "profile" {
+			return
+		}
+		if _, ok := os.LookupEnv(EnvName(f.Name)); ok {
+			err = setFromEnv(f.Name, explicit)
+			return
+		}
+		values, ok := fileValues[f.Name]
+		if !ok {
+			return
+		}
+		if _, isArray := f.Value.(*arrayFlags); !isArray && len(values) != 1 {
+			err = fmt.Errorf("%s: key %q expects a single value", configFile, f.Name)
+			return
+		}
+		for _, v := range values {
+			if setErr := f.Value.Set(v); setErr != nil {
+				err = fmt.Errorf("%s: invalid value %q for key %q: %s", configFile, v, f.Name, setErr)
+				return
+			}
+		}
+	})
+
+	return err
+}
+
+// setFromEnv setzt ein Flag aus seiner ROVER_*-Umgebungsvariable, falls vorhanden.
+// Listen werden kommagetrennt angegeben.
+func setFromEnv(name string, explicit map[string]bool) error {
+	if explicit[name] {
+		return nil
+	}
+
+	env := EnvName(name)
+	value, ok := os.LookupEnv(env)
+	if !ok {
+		return nil
+	}
+
+	f := flag.Lookup(name)
+	values := []string{value}
+	if _, isArray := f.Value.(*arrayFlags); isArray {
+		values = strings.Split(value, ",")
+	}
+
+	for _, v := range values {
+		if err := f.Value.Set(strings.TrimSpace(v)); err != nil {
+			return fmt.Errorf("invalid value %q for environment variable %s: %s", v, env, err)
+		}
+	}
+
+	return nil
+}
+
+// EnvName liefert die Umgebungsvariable zu einem Flag, z.B. planJSONPath -> ROVER_PLAN_JSON_PATH
+func EnvName(flagName string) string {
+	runes := []rune(flagName)
+	var b strings.Builder
+	b.WriteString("ROVER_")
+	for i, c := range runes {
+		if i > 0 && unicode.IsUpper(c) {
+			prevLower := unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(c))
+	}
+	return b.String()
 }
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadConfig ruft LoadConfig mit den Argumenten und einer Konfigurationsdatei im workingDir auf
+func loadConfig(t *testing.T, fileName string, file string, args ...string) (*Config, error) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if file != "" {
+		if err := os.WriteFile(filepath.Join(dir, fileName), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// LoadConfig definiert die Flags auf flag.CommandLine und liest os.Args
+	oldArgs, oldCommandLine := os.Args, flag.CommandLine
+	t.Cleanup(func() {
+		os.Args, flag.CommandLine = oldArgs, oldCommandLine
+		log.SetOutput(os.Stderr)
+	})
+	flag.CommandLine = flag.NewFlagSet("rover", flag.ContinueOnError)
+	flag.CommandLine.SetOutput(io.Discard)
+	os.Args = append([]string{"rover", "-workingDir", dir}, args...)
+	log.SetOutput(io.Discard)
+
+	return LoadConfig()
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  string
+		args []string
+		want string
+	}{
+		{name: "default", want: "rover"},
+		{name: "file", file: "name: file\n", want: "file"},
+		{name: "env over file", file: "name: file\n", env: "env", want: "env"},
+		{name: "flag over env", file: "name: file\n", env: "env", args: []string{"-name", "flag"}, want: "flag"},
+		{name: "profile over file", file: "name: file\nprofiles:\n  ci:\n    name: ci\n", args: []string{"-profile", "ci"}, want: "ci"},
+		{name: "env over profile", file: "name: file\nprofiles:\n  ci:\n    name: ci\n", env: "env", args: []string{"-profile", "ci"}, want: "env"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("ROVER_NAME", tt.env)
+			}
+			config, err := loadConfig(t, ".rover.yaml", tt.file, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Name != tt.want {
+				t.Errorf("Name = %q, want %q", config.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		file     string
+		args     []string
+		want     string
+	}{
+		{name: "unknown profile", fileName: ".rover.yaml", file: "profiles:\n  ci:\n    name: ci\n", args: []string{"-profile", "prod"}, want: `unknown profile "prod" (available: ci)`},
+		{name: "profile without file", args: []string{"-profile", "ci"}, want: `profile "ci" requested`},
+		{name: "unknown key", fileName: ".rover.yaml", file: "colour: red\n", want: `unknown key "colour"`},
+		{name: "unknown key in profile", fileName: ".rover.yaml", file: "profiles:\n  ci:\n    colour: red\n", args: []string{"-profile", "ci"}, want: `unknown key "colour"`},
+		{name: "unknown key in hcl", fileName: ".rover.hcl", file: "colour = \"red\"\n", want: `unknown key "colour"`},
+		{name: "invalid value", fileName: ".rover.yaml", file: "parallelism: many\n", want: `invalid value "many" for key "parallelism"`},
+		{name: "list for scalar", fileName: ".rover.hcl", file: "name = [\"a\", \"b\"]\n", want: `key "name" expects a single value`},
+		{name: "workingDir", fileName: ".rover.yaml", file: "workingDir: other\n", want: "workingDir cannot be set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(t, tt.fileName, tt.file, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadConfig() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"name":             "ROVER_NAME",
+		"planJSONPath":     "ROVER_PLAN_JSON_PATH",
+		"tfcWorkspace":     "ROVER_TFC_WORKSPACE",
+		"tfVarsFile":       "ROVER_TF_VARS_FILE",
+		"ipPort":           "ROVER_IP_PORT",
+		"detailedExitCode": "ROVER_DETAILED_EXIT_CODE",
+	}
+	for flagName, want := range tests {
+		if got := EnvName(flagName); got != want {
+			t.Errorf("EnvName(%s) = %s, want %s", flagName, got, want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+// Standard-Dateinamen, nach denen im WorkingDir gesucht wird
+var defaultConfigFiles = []string{".rover.yaml", ".rover.yml", ".rover.hcl"}
+
+// fileConfig enthält die Werte einer Konfigurationsdatei, nach Flag-Namen geordnet
+type fileConfig struct {
+	Path     string
+	Values   map[string][]string
+	Profiles map[string]map[string][]string
+}
+
+// findConfigFile sucht eine Standard-Konfigurationsdatei im angegebenen Verzeichnis
+func findConfigFile(dir string) string {
+	for _, name := range defaultConfigFiles {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadConfigFile liest eine YAML- oder HCL-Konfigurationsdatei
+func loadConfigFile(path string) (*fileConfig, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file (%s): %s", path, err)
+	}
+
+	fc := &fileConfig{
+		Path:     path,
+		Values:   map[string][]string{},
+		Profiles: map[string]map[string][]string{},
+	}
+
+	if strings.HasSuffix(path, ".hcl") {
+		err = parseHCLConfig(fc, src)
+	} else {
+		err = parseYAMLConfig(fc, src)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return fc, nil
+}
+
+// values gibt die Werte der Datei zurück, überlagert mit denen des gewählten Profils
+func (fc *fileConfig) values(profile string) (map[string][]string, error) {
+	values := map[string][]string{}
+	for k, v := range fc.Values {
+		values[k] = v
+	}
+
+	if profile == "" {
+		return values, nil
+	}
+
+	p, ok := fc.Profiles[profile]
+	if !ok {
+		names := make([]string, 0, len(fc.Profiles))
+		for name := range fc.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("%s: unknown profile %q (available: %s)", fc.Path, profile, strings.Join(names, ", "))
+	}
+
+	for k, v := range p {
+		values[k] = v
+	}
+
+	return values, nil
+}
+
+func parseYAMLConfig(fc *fileConfig, src []byte) error {
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(src, &raw); err != nil {
+		return fmt.Errorf("%s: %s", fc.Path, err)
+	}
+
+	for key, value := range raw {
+		if key != "profiles" {
+			v, err := yamlValue(fc.Path, key, value)
+			if err != nil {
+				return err
+			}
+			fc.Values[key] = v
+			continue
+		}
+
+		profiles, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: key %q must be a map of profile names", fc.Path, key)
+		}
+		for name, p := range profiles {
+			entries, ok := p.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: key \"profiles.%s\" must be a map", fc.Path, name)
+			}
+			fc.Profiles[name] = map[string][]string{}
+			for k, v := range entries {
+				pv, err := yamlValue(fc.Path, fmt.Sprintf("profiles.%s.%s", name, k), v)
+				if err != nil {
+					return err
+				}
+				fc.Profiles[name][k] = pv
+			}
+		}
+	}
+
+	return nil
+}
+
+func yamlValue(path string, key string, value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, fmt.Errorf("%s: key %q has no value", path, key)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, el := range v {
+			switch el.(type) {
+			case []interface{}, map[string]interface{}, nil:
+				return nil, fmt.Errorf("%s: key %q must be a list of scalar values", path, key)
+			}
+			values = append(values, fmt.Sprint(el))
+		}
+		return values, nil
+	case map[string]interface{}:
+		return nil, fmt.Errorf("%s: key %q must be a scalar value or a list", path, key)
+	}
+	return []string{fmt.Sprint(value)}, nil
+}
+
+func parseHCLConfig(fc *fileConfig, src []byte) error {
+	file, diags := hclsyntax.ParseConfig(src, fc.Path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return diags
+	}
+
+	body := file.Body.(*hclsyntax.Body)
+
+	values, err := hclValues(fc.Path, "", body.Attributes)
+	if err != nil {
+		return err
+	}
+	fc.Values = values
+
+	for _, block := range body.Blocks {
+		if block.Type != "profile" || len(block.Labels) != 1 {
+			return fmt.Errorf("%s:%d: unexpected block %q, only profile \"<name>\" blocks are supported", fc.Path, block.TypeRange.Start.Line, block.Type)
+		}
+		name := block.Labels[0]
+		if len(block.Body.Blocks) > 0 {
+			return fmt.Errorf("%s:%d: profile %q must not contain nested blocks", fc.Path, block.TypeRange.Start.Line, name)
+		}
+		pv, err := hclValues(fc.Path, fmt.Sprintf("profile.%s.", name), block.Body.Attributes)
+		if err != nil {
+			return err
+		}
+		fc.Profiles[name] = pv
+	}
+
+	return nil
+}
+
+func hclValues(path string, prefix string, attrs hclsyntax.Attributes) (map[string][]string, error) {
+	values := map[string][]string{}
+
+	for name, attr := range attrs {
+		key := prefix + name
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("%s: key %q: %s", path, key, diags.Error())
+		}
+
+		if val.IsNull() {
+			return nil, fmt.Errorf("%s: key %q has no value", path, key)
+		}
+
+		ty := val.Type()
+		if ty.IsListType() || ty.IsTupleType() || ty.IsSetType() {
+			var list []string
+			for it := val.ElementIterator(); it.Next(); {
+				_, el := it.Element()
+				s, err := ctyString(el)
+				if err != nil {
+					return nil, fmt.Errorf("%s: key %q must be a list of scalar values", path, key)
+				}
+				list = append(list, s)
+			}
+			values[name] = list
+			continue
+		}
+
+		s, err := ctyString(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q must be a scalar value or a list", path, key)
+		}
+		values[name] = []string{s}
+	}
+
+	return values, nil
+}
+
+func ctyString(val cty.Value) (string, error) {
+	if val.IsNull() || !val.IsKnown() {
+		return "", fmt.Errorf("value is not known")
+	}
+	switch val.Type() {
+	case cty.String:
+		return val.AsString(), nil
+	case cty.Bool:
+		if val.True() {
+			return "true", nil
+		}
+		return "false", nil
+	case cty.Number:
+		return val.AsBigFloat().Text('f', -1), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", val.Type().FriendlyName())
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+type ConfigDiffStatus string
+
+const (
+	ConfigDiffAdded     ConfigDiffStatus = "added"
+	ConfigDiffRemoved   ConfigDiffStatus = "removed"
+	ConfigDiffMovedFile ConfigDiffStatus = "moved-file"
+	ConfigDiffUnchanged ConfigDiffStatus = "unchanged"
+)
+
+// ConfigDiff is the structural difference of the configuration between two git refs
+type ConfigDiff struct {
+	RefA    string                   `json:"ref_a"`
+	RefB    string                   `json:"ref_b"`
+	Totals  map[ConfigDiffStatus]int `json:"totals"`
+	Changes []ConfigDiffChange       `json:"changes"`
+	Graph   Graph                    `json:"graph"`
+}
+
+// ConfigDiffChange is the status of a module call, resource, variable, output or local
+type ConfigDiffChange struct {
+	Address string           `json:"address"`
+	Type    ResourceType     `json:"type"`
+	Status  ConfigDiffStatus `json:"status"`
+	FileA   string           `json:"file_a,omitempty"`
+	FileB   string           `json:"file_b,omitempty"`
+}
+
+// GenerateConfigOverview builds the RSO from the configuration files only, without a plan.
+// Module calls with a local source are loaded relative to their parent, other
+// modules from locations (keyed like modules.json, e.g. network.subnets).
+func (r *rover) GenerateConfigOverview(locations map[string]string) error {
+	log.Println("Generating configuration overview...")
+
+	root, diags := tfconfig.LoadModule(r.WorkingDir)
+	if diags.HasErrors() {
+		return fmt.Errorf("Unable to load configuration (%s): %s", r.WorkingDir, diags.Err())
+	}
+
+	rso := &ResourcesOverview{
+		Locations: locations,
+		Configs:   make(map[string]*ConfigOverview),
+		States:    make(map[string]*StateOverview),
+	}
+	rso.States[""] = &StateOverview{
+		Type:     ResourceTypeModule,
+		Children: make(map[string]*StateOverview),
+	}
+
+	r.populateModuleConfig(rso, "", "", root, &tfjson.ModuleCall{})
+
+	r.RSO = rso
+
+	return nil
+}
+
+func (r *rover) populateModuleConfig(rso *ResourcesOverview, address string, key string, module *tfconfig.Module, call *tfjson.ModuleCall) {
+	rc := rso.Configs
+	rs := rso.States
+
+	prefix := address
+	if prefix != "" {
+		prefix = fmt.Sprintf("%s.", prefix)
+	}
+
+	blocks := loadBlockExpressions(module.Path)
+
+	rc[address] = &ConfigOverview{
+		Module:       module,
+		ModuleConfig: call,
+		Locals:       loadLocals(module.Path),
+	}
+
+	for name, l := range rc[address].Locals {
+		rc[prefix+"local."+name] = &ConfigOverview{LocalConfig: l}
+	}
+
+	for name, v := range module.Variables {
+		rc[prefix+"var."+name] = &ConfigOverview{
+			VariableConfig: &tfjson.ConfigVariable{
+				Default:     v.Default,
+				Description: v.Description,
+			},
+		}
+	}
+
+	for name, o := range module.Outputs {
+		rc[prefix+"output."+name] = &ConfigOverview{
+			OutputConfig: &tfjson.ConfigOutput{
+				Sensitive:   o.Sensitive,
+				Description: o.Description,
+				Expression:  blocks["output."+name]["value"],
+			},
+		}
+	}
+
+	addResources := func(resources map[string]*tfconfig.Resource, mode tfjson.ResourceMode, resourceType ResourceType) {
+		for addr, resource := range resources {
+			id := prefix + addr
+			rc[id] = &ConfigOverview{
+				ResourceConfig: &tfjson.ConfigResource{
+					Address:           addr,
+					Mode:              mode,
+					Type:              resource.Type,
+					Name:              resource.Name,
+					ProviderConfigKey: resource.Provider.Name,
+					Expressions:       blocks[addr],
+				},
+			}
+			rs[id] = &StateOverview{
+				Type:     resourceType,
+				Children: make(map[string]*StateOverview),
+			}
+			rs[address].Children[id] = rs[id]
+		}
+	}
+	addResources(module.ManagedResources, tfjson.ManagedResourceMode, ResourceTypeResource)
+	addResources(module.DataResources, tfjson.DataResourceMode, ResourceTypeData)
+
+	for name, mc := range module.ModuleCalls {
+		id := fmt.Sprintf("%smodule.%s", prefix, name)
+		childKey := name
+		if key != "" {
+			childKey = fmt.Sprintf("%s.%s", key, name)
+		}
+
+		childCall := &tfjson.ModuleCall{
+			Source:            mc.Source,
+			VersionConstraint: mc.Version,
+			Expressions:       blocks["module."+name],
+		}
+
+		rs[id] = &StateOverview{
+			Type:     ResourceTypeModule,
+			Children: make(map[string]*StateOverview),
+		}
+		rs[address].Children[id] = rs[id]
+
+		dir := rso.Locations[childKey]
+		if strings.HasPrefix(mc.Source, "./") || strings.HasPrefix(mc.Source, "../") {
+			dir = filepath.Join(module.Path, mc.Source)
+		}
+
+		if dir != "" {
+			child, diags := tfconfig.LoadModule(dir)
+			if !diags.HasErrors() {
+				r.populateModuleConfig(rso, id, childKey, child, childCall)
+				continue
+			}
+		}
+
+		log.Printf("Continuing without loading module from filesystem: %s\n", childKey)
+		rc[id] = &ConfigOverview{ModuleConfig: childCall}
+	}
+}
+
+// loadBlockExpressions parses the references of the resource, data, module and output
+// blocks of a module directory, keyed by block address and attribute name.
+// Nested blocks are keyed by their type.
+func loadBlockExpressions(dir string) map[string]map[string]*tfjson.Expression {
+	blocks := map[string]map[string]*tfjson.Expression{}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return blocks
+	}
+
+	parser := hclparse.NewParser()
+
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".tf") {
+			continue
+		}
+
+		file, diags := parser.ParseHCLFile(filepath.Join(dir, f.Name()))
+		if diags.HasErrors() {
+			continue
+		}
+
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+
+		for _, block := range body.Blocks {
+			var address string
+			switch {
+			case block.Type == "resource" && len(block.Labels) == 2:
+				address = fmt.Sprintf("%s.%s", block.Labels[0], block.Labels[1])
+			case block.Type == "data" && len(block.Labels) == 2:
+				address = fmt.Sprintf("data.%s.%s", block.Labels[0], block.Labels[1])
+			case block.Type == "module" && len(block.Labels) == 1:
+				address = fmt.Sprintf("module.%s", block.Labels[0])
+			case block.Type == "output" && len(block.Labels) == 1:
+				address = fmt.Sprintf("output.%s", block.Labels[0])
+			default:
+				continue
+			}
+
+			expressions := map[string]*tfjson.Expression{}
+			addBodyExpressions(expressions, "", block.Body)
+			blocks[address] = expressions
+		}
+	}
+
+	return blocks
+}
+
+func addBodyExpressions(expressions map[string]*tfjson.Expression, key string, body *hclsyntax.Body) {
+	for name, attr := range body.Attributes {
+		attrKey := name
+		if key != "" {
+			attrKey = key
+		}
+		addReferences(expressions, attrKey, expressionReferences(attr.Expr))
+	}
+
+	for _, nested := range body.Blocks {
+		nestedKey := key
+		if nestedKey == "" {
+			nestedKey = nested.Type
+			// Dynamic blocks are keyed by the generated block type
+			if nested.Type == "dynamic" && len(nested.Labels) == 1 {
+				nestedKey = nested.Labels[0]
+			}
+		}
+		addBodyExpressions(expressions, nestedKey, nested.Body)
+	}
+}
+
+func addReferences(expressions map[string]*tfjson.Expression, key string, references []string) {
+	for _, ref := range references {
+		// Skip references that are not nodes, like the plan does
+		switch strings.SplitN(ref, ".", 2)[0] {
+		case "count", "each", "self", "path":
+			continue
+		}
+		if _, ok := expressions[key]; !ok {
+			expressions[key] = &tfjson.Expression{ExpressionData: &tfjson.ExpressionData{}}
+		}
+		expressions[key].References = append(expressions[key].References, ref)
+	}
+}
+
+// GenerateConfigDiff compares the configuration of WorkingDir at two git refs
+// It also returns the rovers of refA and refB, whose configuration is served with the diff
+func (r *rover) GenerateConfigDiff(refA string, refB string) (*ConfigDiff, *rover, *rover, error) {
+	repo, err := loadGitRepo(r.WorkingDir)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Unable to read git repository: %s", err)
+	}
+
+	workingDir, err := filepath.Abs(r.WorkingDir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(workingDir); err == nil {
+		workingDir = resolved
+	}
+	rel, err := filepath.Rel(repo.Root, workingDir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, nil, nil, fmt.Errorf("%s is not inside the git repository %s", r.WorkingDir, repo.Root)
+	}
+
+	// Remote modules are only available from the current .terraform directory
+	locations := map[string]string{}
+	r.PopulateModuleLocations(filepath.Join(r.WorkingDir, ".terraform/modules/modules.json"), locations)
+	for key, dir := range locations {
+		if abs, err := filepath.Abs(dir); err == nil {
+			locations[key] = abs
+		}
+	}
+
+	a, err := r.configAtRef(repo, refA, rel, locations)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	b, err := r.configAtRef(repo, refB, rel, locations)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	diff := &ConfigDiff{
+		RefA:    refA,
+		RefB:    refB,
+		Totals:  map[ConfigDiffStatus]int{},
+		Changes: []ConfigDiffChange{},
+	}
+
+	locA := a.Map.Locations()
+	locB := b.Map.Locations()
+
+	statuses := map[string]ConfigDiffStatus{}
+	for _, address := range sortedKeys(mergeLocations(locA, locB)) {
+		la, inA := locA[address]
+		lb, inB := locB[address]
+
+		change := ConfigDiffChange{
+			Address: address,
+			FileA:   la.File,
+			FileB:   lb.File,
+		}
+
+		switch {
+		case !inA:
+			change.Status = ConfigDiffAdded
+		case !inB:
+			change.Status = ConfigDiffRemoved
+		case la.File != lb.File:
+			change.Status = ConfigDiffMovedFile
+		default:
+			change.Status = ConfigDiffUnchanged
+		}
+
+		if config := b.RSO.Configs[address]; inB && config != nil {
+			change.Type = configType(config)
+		} else if config := a.RSO.Configs[address]; config != nil {
+			change.Type = configType(config)
+		}
+
+		statuses[address] = change.Status
+		diff.Totals[change.Status]++
+		diff.Changes = append(diff.Changes, change)
+	}
+
+	diff.Graph = mergeGraphs(a.Graph, b.Graph, statuses)
+
+	return diff, a, b, nil
+}
+
+// configAtRef checks out ref into a temporary worktree and generates the Map and Graph of its configuration.
+// The worktree is kept until r.cleanup, since /api/source reads the files of the configuration.
+func (r *rover) configAtRef(repo *gitRepo, ref string, rel string, locations map[string]string) (*rover, error) {
+	log.Printf("Loading configuration at %s...", ref)
+
+	tmpDir, err := os.MkdirTemp("", "rover-worktree")
+	if err != nil {
+		return nil, err
+	}
+	r.cleanups = append(r.cleanups, func() {
+		runGit(repo.Root, "worktree", "remove", "--force", tmpDir)
+		os.RemoveAll(tmpDir)
+	})
+
+	if _, err := runGit(repo.Root, "worktree", "add", "--detach", tmpDir, ref); err != nil {
+		return nil, fmt.Errorf("Unable to check out %s: %s", ref, err)
+	}
+
+	c := &rover{
+		Name:       r.Name,
+		WorkingDir: filepath.Join(tmpDir, rel),
+	}
+
+	if err := c.GenerateConfigOverview(locations); err != nil {
+		return nil, fmt.Errorf("%s: %s", ref, err)
+	}
+	if err := c.GenerateMap(); err != nil {
+		return nil, err
+	}
+
+	// Both graphs share the root node, which would be named after the worktree
+	c.Map.Path = filepath.ToSlash(rel)
+
+	if err := c.GenerateGraph(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func configType(config *ConfigOverview) ResourceType {
+	switch {
+	case config.ResourceConfig != nil && config.ResourceConfig.Mode == tfjson.DataResourceMode:
+		return ResourceTypeData
+	case config.ResourceConfig != nil:
+		return ResourceTypeResource
+	case config.VariableConfig != nil:
+		return ResourceTypeVariable
+	case config.OutputConfig != nil:
+		return ResourceTypeOutput
+	case config.LocalConfig != nil:
+		return ResourceTypeLocal
+	}
+	return ResourceTypeModule
+}
+
+func mergeLocations(a map[string]MapLocation, b map[string]MapLocation) map[string]MapLocation {
+	merged := make(map[string]MapLocation, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
+
+// mergeGraphs combines the graphs of both refs. Nodes and edges of b take precedence,
+// nodes are classed by their status, or by their presence for grouping nodes like files.
+func mergeGraphs(a Graph, b Graph, statuses map[string]ConfigDiffStatus) Graph {
+	inA := map[string]bool{}
+	for _, n := range a.Nodes {
+		inA[n.Data.ID] = true
+	}
+	inB := map[string]bool{}
+	for _, n := range b.Nodes {
+		inB[n.Data.ID] = true
+	}
+
+	classify := func(n Node) Node {
+		status, ok := statuses[n.Data.ID]
+		if !ok {
+			switch {
+			case !inA[n.Data.ID]:
+				status = ConfigDiffAdded
+			case !inB[n.Data.ID]:
+				status = ConfigDiffRemoved
+			default:
+				status = ConfigDiffUnchanged
+			}
+		}
+		n.Classes = strings.Join(strings.Fields(fmt.Sprintf("%s %s", n.Classes, status)), " ")
+		return n
+	}
+
+	merged := Graph{Nodes: []Node{}, Edges: []Edge{}}
+	for _, n := range b.Nodes {
+		merged.Nodes = append(merged.Nodes, classify(n))
+	}
+	for _, n := range a.Nodes {
+		if !inB[n.Data.ID] {
+			merged.Nodes = append(merged.Nodes, classify(n))
+		}
+	}
+
+	edgesA := map[string]bool{}
+	for _, e := range a.Edges {
+		edgesA[e.Data.ID] = true
+	}
+	edgesB := map[string]bool{}
+	for _, e := range b.Edges {
+		edgesB[e.Data.ID] = true
+		if !edgesA[e.Data.ID] {
+			e.Classes = strings.TrimSpace(fmt.Sprintf("%s %s", e.Classes, ConfigDiffAdded))
+		}
+		merged.Edges = append(merged.Edges, e)
+	}
+	for _, e := range a.Edges {
+		if !edgesB[e.Data.ID] {
+			e.Classes = strings.TrimSpace(fmt.Sprintf("%s %s", e.Classes, ConfigDiffRemoved))
+			merged.Edges = append(merged.Edges, e)
+		}
+	}
+
+	sort.SliceStable(merged.Edges, func(i, j int) bool {
+		return merged.Edges[i].Data.ID < merged.Edges[j].Data.ID
+	})
+
+	return merged
+}
+
+// Write writes the diff as JSON
+func (d *ConfigDiff) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// generateConfigDiffAssets compares the configuration at the refs of "config-diff <refA> <refB>"
+// and replaces the Map and Graph with those of refB and the merged graph
+func (r *rover) generateConfigDiffAssets(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("Usage: rover config-diff [flags] <refA> <refB>")
+	}
+
+	diff, a, b, err := r.GenerateConfigDiff(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	r.ConfigDiff = diff
+	r.Plan = &tfjson.Plan{}
+	r.RSO = b.RSO
+	r.baseRSO = a.RSO
+	r.Map = b.Map
+	r.Graph = diff.Graph
+
+	log.Printf("Configuration diff: %d added, %d removed, %d moved, %d unchanged",
+		diff.Totals[ConfigDiffAdded], diff.Totals[ConfigDiffRemoved], diff.Totals[ConfigDiffMovedFile], diff.Totals[ConfigDiffUnchanged])
+
+	return nil
+}
+
+// writeConfigDiff exports the diff to a file or stdout
+func (r *rover) writeConfigDiff(path string, format string) error {
+	if format != "" && format != "json" {
+		return fmt.Errorf("unknown config-diff format %q (available: json)", format)
+	}
+
+	out, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	return r.ConfigDiff.Write(out)
+}
@@ -0,0 +1,17 @@
+rule "no-prod-delete" {
+  severity = "critical"
+  message  = "Resource tagged env=prod is deleted"
+  action   = ["delete", "replace"]
+
+  attribute {
+    path   = "tags.env"
+    equals = "prod"
+  }
+}
+
+rule "allowed-resource-types" {
+  severity = "low"
+  message  = "Resource type is not on the allowlist"
+  not_type = ["aws_*", "random_*"]
+  action   = ["create"]
+}
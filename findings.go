@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// Severities in ascending order
+var severities = []Severity{SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// ExitFindings is the exit code if findings reach the -failOnSeverity threshold
+const ExitFindings = 4
+
+// Finding is a problem reported about a node of the plan or configuration
+type Finding struct {
+	RuleID   string   `json:"rule_id"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Address  string   `json:"address"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+}
+
+// FindingRule describes a rule that was evaluated, with or without findings
+type FindingRule struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description,omitempty"`
+	Severity    Severity `json:"severity"`
+	Category    string   `json:"category"`
+}
+
+var moduleAddress = regexp.MustCompile(`^(module\.[^.\[]+(\[[^\]]*\])?\.)*`)
+
+func severityLevel(s Severity) int {
+	for i, sev := range severities {
+		if sev == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// annotateFindings attaches the findings to the graph node of their address.
+// Findings of resource instances without a node of their own go to the resource node.
+func (r *rover) annotateFindings() {
+
+	index := make(map[string]int, len(r.Graph.Nodes))
+	for i, n := range r.Graph.Nodes {
+		index[n.Data.ID] = i
+		r.Graph.Nodes[i].Data.Findings = nil
+	}
+
+	for _, f := range r.Findings {
+		i, ok := index[f.Address]
+		if !ok {
+			i, ok = index[matchBrackets.ReplaceAllString(f.Address, "")]
+		}
+		if ok {
+			r.Graph.Nodes[i].Data.Findings = append(r.Graph.Nodes[i].Data.Findings, f)
+		}
+	}
+}
+
+// locateFindings sets the file and line of findings from the map.
+// File paths are relative to the current directory if possible.
+func (r *rover) locateFindings(findings []Finding) {
+	locations := r.Map.Locations()
+
+	cwd, _ := os.Getwd()
+
+	for i, f := range findings {
+		file, line := f.File, f.Line
+
+		if loc, ok := locations[f.Address]; ok && loc.File != "" && loc.File != DefaultFileName {
+			module := strings.TrimSuffix(moduleAddress.FindString(f.Address), ".")
+			config := r.RSO.Configs[matchBrackets.ReplaceAllString(module, "")]
+			if config != nil && config.Module != nil {
+				file = filepath.Join(config.Module.Path, loc.File)
+				line = loc.Line
+			}
+		}
+
+		if file == "" {
+			continue
+		}
+
+		if abs, err := filepath.Abs(file); err == nil && cwd != "" {
+			if rel, err := filepath.Rel(cwd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				file = rel
+			}
+		}
+
+		findings[i].File = filepath.ToSlash(file)
+		findings[i].Line = line
+	}
+}
+
+// failsOnFindings reports whether any finding reaches the severity threshold
+func (r *rover) failsOnFindings(threshold string) bool {
+	if threshold == "" {
+		return false
+	}
+	level := severityLevel(Severity(threshold))
+	for _, f := range r.Findings {
+		if severityLevel(f.Severity) >= level {
+			return true
+		}
+	}
+	return false
+}
+
+// exportedFindings returns the rules and findings of the SARIF and JUnit exports:
+// the policy findings and the lint results, which don't count towards -failOnSeverity
+func (r *rover) exportedFindings() ([]FindingRule, []Finding) {
+	rules := append([]FindingRule{}, r.Rules...)
+	findings := append([]Finding{}, r.Findings...)
+	if r.Lint != nil {
+		rules = append(rules, lintRules...)
+		findings = append(findings, r.Lint...)
+	}
+	return rules, findings
+}
+
+// writeFindings exports the findings to the given SARIF and JUnit files, if set
+func (r *rover) writeFindings(sarifPath string, junitPath string, version string) error {
+	if sarifPath != "" {
+		f, err := os.Create(sarifPath)
+		if err != nil {
+			return err
+		}
+		err = r.WriteSARIF(f, version)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if junitPath != "" {
+		f, err := os.Create(junitPath)
+		if err != nil {
+			return err
+		}
+		err = r.WriteJUnit(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string            `json:"id"`
+	ShortDescription     sarifMessage      `json:"shortDescription"`
+	DefaultConfiguration sarifRuleConfig   `json:"defaultConfiguration"`
+	Properties           map[string]string `json:"properties,omitempty"`
+}
+
+type sarifRuleConfig struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
+
+func sarifLevel(s Severity) string {
+	switch s {
+	case SeverityCritical, SeverityHigh:
+		return "error"
+	case SeverityMedium:
+		return "warning"
+	}
+	return "note"
+}
+
+// WriteSARIF writes the findings as a SARIF 2.1.0 log
+func (r *rover) WriteSARIF(w io.Writer, version string) error {
+	driver := sarifDriver{
+		Name:           "rover",
+		Version:        version,
+		InformationURI: "https://github.com/im2nguyen/rover",
+		Rules:          []sarifRule{},
+	}
+
+	rules, findings := r.exportedFindings()
+	for _, rule := range rules {
+		description := rule.Description
+		if description == "" {
+			description = rule.ID
+		}
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   rule.ID,
+			ShortDescription:     sarifMessage{Text: description},
+			DefaultConfiguration: sarifRuleConfig{Level: sarifLevel(rule.Severity)},
+			Properties:           map[string]string{"category": rule.Category},
+		})
+	}
+
+	results := []sarifResult{}
+	for _, f := range findings {
+		loc := sarifLocation{
+			LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: f.Address}},
+		}
+		if f.File != "" {
+			loc.PhysicalLocation = &sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: f.File},
+			}
+			if f.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: f.Line}
+			}
+		}
+
+		results = append(results, sarifResult{
+			RuleID:    f.RuleID,
+			Level:     sarifLevel(f.Severity),
+			Message:   sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{loc},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: driver},
+			Results: results,
+		}},
+	})
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes one test case per rule, failing if the rule has findings
+func (r *rover) WriteJUnit(w io.Writer) error {
+	suite := junitTestSuite{
+		Name:      "rover",
+		TestCases: []junitTestCase{},
+	}
+
+	rules, findings := r.exportedFindings()
+	for _, rule := range rules {
+		tc := junitTestCase{
+			Name:      rule.ID,
+			ClassName: fmt.Sprintf("rover.%s", rule.Category),
+		}
+
+		var lines []string
+		for _, f := range findings {
+			if f.RuleID != rule.ID {
+				continue
+			}
+			location := f.Address
+			if f.File != "" {
+				location = fmt.Sprintf("%s (%s:%d)", f.Address, f.File, f.Line)
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", location, f.Message))
+		}
+
+		if len(lines) > 0 {
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d finding(s)", len(lines)),
+				Type:    string(rule.Severity),
+				Text:    strings.Join(lines, "\n"),
+			}
+			suite.Failures++
+		}
+
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLintExport(t *testing.T) {
+	r := &rover{
+		Findings: []Finding{},
+		Lint: []Finding{{
+			RuleID:   "unused-variable",
+			Severity: SeverityLow,
+			Message:  `Variable "unused" is declared but not referenced`,
+			Address:  "var.unused",
+			File:     "variables.tf",
+			Line:     12,
+		}},
+	}
+
+	// Lint results never fail the run
+	if r.failsOnFindings(string(SeverityInfo)) {
+		t.Error("failsOnFindings(info) = true with lint results only, want false")
+	}
+
+	var sarif bytes.Buffer
+	if err := r.WriteSARIF(&sarif, "test"); err != nil {
+		t.Fatal(err)
+	}
+	log := sarifLog{}
+	if err := json.Unmarshal(sarif.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 1 || results[0].RuleID != "unused-variable" {
+		t.Fatalf("results = %+v, want the unused-variable result", results)
+	}
+	if loc := results[0].Locations[0].PhysicalLocation; loc == nil || loc.ArtifactLocation.URI != "variables.tf" || loc.Region.StartLine != 12 {
+		t.Errorf("physical location = %+v, want variables.tf:12", loc)
+	}
+	category := ""
+	for _, rule := range log.Runs[0].Tool.Driver.Rules {
+		if rule.ID == "unused-variable" {
+			category = rule.Properties["category"]
+		}
+	}
+	if category != "lint" {
+		t.Errorf("category of unused-variable = %q, want lint", category)
+	}
+
+	var junit bytes.Buffer
+	if err := r.WriteJUnit(&junit); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(junit.String(), `classname="rover.lint"`) || !strings.Contains(junit.String(), "var.unused (variables.tf:12)") {
+		t.Errorf("JUnit report has no failing lint test case:\n%s", junit.String())
+	}
+}
+
+func TestSARIFLocations(t *testing.T) {
+	r := generateRandomTest(t)
+	r.EvaluatePolicies([]*PolicyRule{{ID: "pets", Severity: SeverityLow, Address: "*random_pet.pet"}, {ID: "dog", Severity: SeverityLow, Address: "random_pet.dog"}})
+
+	var sarif bytes.Buffer
+	if err := r.WriteSARIF(&sarif, "test"); err != nil {
+		t.Fatal(err)
+	}
+	log := sarifLog{}
+	if err := json.Unmarshal(sarif.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	// Locations come from the file and Resource.Line of the map, joined with the module path
+	want := map[string]string{
+		"module.random_cat.random_pet.pet": "example/random-test/random-name/main.tf:11",
+		"random_pet.dog":                   "example/random-test/main.tf:22",
+	}
+	results := log.Runs[0].Results
+	if len(results) != len(want) {
+		t.Fatalf("results = %+v, want %d", results, len(want))
+	}
+	for _, result := range results {
+		address := result.Locations[0].LogicalLocations[0].FullyQualifiedName
+		loc := result.Locations[0].PhysicalLocation
+		if loc == nil {
+			t.Errorf("%s has no physical location, want %s", address, want[address])
+			continue
+		}
+		if got := fmt.Sprintf("%s:%d", loc.ArtifactLocation.URI, loc.Region.StartLine); got != want[address] {
+			t.Errorf("location of %s = %s, want %s", address, got, want[address])
+		}
+	}
+}
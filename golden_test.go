@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestGoldenRandomTest generates rso, map and graph of example/random-test from the plan in
+// testdata/random-test and compares them to the golden files, go test -run Golden -update rewrites them
+func TestGoldenRandomTest(t *testing.T) {
+	r := &rover{
+		WorkingDir:   "example/random-test",
+		PlanJSONPath: "testdata/random-test/plan.json",
+	}
+	if err := r.getPlan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	r.updateMeta()
+	if err := r.GenerateResourceOverview(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.GenerateMap(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.GenerateGraph(); err != nil {
+		t.Fatal(err)
+	}
+
+	assets := map[string]interface{}{
+		"rso":   r.RSO,
+		"map":   r.Map,
+		"graph": r.Graph,
+	}
+	for name, asset := range assets {
+		t.Run(name, func(t *testing.T) {
+			got, err := json.MarshalIndent(asset, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", "random-test", name+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s differs from %s, run go test -run Golden -update and review the diff", name, golden)
+			}
+		})
+	}
+}
@@ -3,10 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
-	"regexp"
 	"strings"
-
-	tfjson "github.com/hashicorp/terraform-json"
 )
 
 const (
@@ -40,6 +37,20 @@ type NodeData struct {
 	Parent      string       `json:"parent,omitempty"`
 	ParentColor string       `json:"parentColor,omitempty"`
 	Change      string       `json:"change,omitempty"`
+	Findings    []Finding    `json:"findings,omitempty"`
+	EditorURL   string       `json:"editorUrl,omitempty"`
+	PlanMode    PlanMode     `json:"planMode,omitempty"`
+	// Resource type and local provider name of resources and data sources
+	ResourceType string `json:"resourceType,omitempty"`
+	Provider     string `json:"provider,omitempty"`
+	// Summary of a module collapsed with collapseDepth
+	Collapsed bool           `json:"collapsed,omitempty"`
+	Resources int            `json:"resources,omitempty"`
+	Changes   map[Action]int `json:"changes,omitempty"`
+	// Git metadata of the declaring block, see -gitBase
+	ChangedInBranch bool   `json:"changedInBranch,omitempty"`
+	LastCommit      string `json:"lastCommit,omitempty"`
+	LastAuthor      string `json:"lastAuthor,omitempty"`
 }
 
 // Edge TODO
@@ -54,6 +65,8 @@ type EdgeData struct {
 	Source   string `json:"source"`
 	Target   string `json:"target"`
 	Gradient string `json:"gradient,omitempty"`
+	// Number of edges merged into an edge to a collapsed module
+	Weight int `json:"weight,omitempty"`
 }
 
 // GenerateGraph -
@@ -92,10 +105,17 @@ func (r *rover) addNodes(base string, parent string, nodeMap map[string]Node, re
 
 	nmo := []string{}
 
-	for id, re := range resources {
+	for _, id := range sortedKeys(resources) {
+		re := resources[id]
 
 		if re.Type == ResourceTypeResource || re.Type == ResourceTypeData {
 
+			// Instances of count and for_each inherit the type and provider of their resource
+			resourceType, provider := re.ResourceType, re.Provider
+			if resourceType == "" {
+				resourceType, provider = nodeMap[parent].Data.ResourceType, nodeMap[parent].Data.Provider
+			}
+
 			pid := parent
 
 			if nodeMap[parent].Data.Type == ResourceTypeFile {
@@ -128,11 +148,13 @@ func (r *rover) addNodes(base string, parent string, nodeMap map[string]Node, re
 			nmo = append(nmo, mid)
 			nodeMap[mid] = Node{
 				Data: NodeData{
-					ID:          mid,
-					Label:       label,
-					Type:        re.Type,
-					Parent:      midParent,
-					ParentColor: getResourceColor(nodeMap[parent].Data.Type),
+					ID:           mid,
+					Label:        label,
+					Type:         re.Type,
+					Parent:       midParent,
+					ParentColor:  getResourceColor(nodeMap[parent].Data.Type),
+					ResourceType: resourceType,
+					Provider:     provider,
 				},
 				Classes: fmt.Sprintf("%s-type", re.Type),
 			}
@@ -143,12 +165,14 @@ func (r *rover) addNodes(base string, parent string, nodeMap map[string]Node, re
 			nmo = append(nmo, id)
 			nodeMap[id] = Node{
 				Data: NodeData{
-					ID:          id,
-					Label:       re.Name,
-					Type:        re.Type,
-					Parent:      mid,
-					ParentColor: getResourceColor(nodeMap[parent].Data.Type),
-					Change:      mrChange,
+					ID:           id,
+					Label:        re.Name,
+					Type:         re.Type,
+					Parent:       mid,
+					ParentColor:  getResourceColor(nodeMap[parent].Data.Type),
+					Change:       mrChange,
+					ResourceType: resourceType,
+					Provider:     provider,
 				},
 				Classes: fmt.Sprintf("%s-name %s", re.Type, mrChange),
 			}
@@ -221,7 +245,7 @@ func (r *rover) GenerateNodes() []Node {
 	basePath := strings.ReplaceAll(r.Map.Path, "./", "")
 
 	nmo = append(nmo, basePath)
-	nodeMap[basePath] = Node{
+	root := Node{
 		Data: NodeData{
 			ID:    basePath,
 			Label: basePath,
@@ -229,6 +253,13 @@ func (r *rover) GenerateNodes() []Node {
 		},
 		Classes: "basename",
 	}
+	// Badge for destroy and refresh-only plans
+	if r.Meta.PlanMode != "" && r.Meta.PlanMode != PlanModeNormal {
+		root.Data.PlanMode = r.Meta.PlanMode
+		root.Data.Label = fmt.Sprintf("%s [%s]", basePath, r.Meta.PlanMode)
+		root.Classes = fmt.Sprintf("basename plan-%s", r.Meta.PlanMode)
+	}
+	nodeMap[basePath] = root
 
 	nmo = append(nmo, r.addNodes(basePath, basePath, nodeMap, r.Map.Root)...)
 
@@ -247,75 +278,57 @@ func (r *rover) GenerateNodes() []Node {
 
 func (r *rover) addEdges(base string, parent string, edgeMap map[string]Edge, resources map[string]*Resource) []string {
 	emo := []string{}
-	for id, re := range resources {
-		matchBrackets := regexp.MustCompile(`\[[^\[\]]*\]`)
+	for _, id := range sortedKeys(resources) {
+		re := resources[id]
 
 		configId := matchBrackets.ReplaceAllString(id, "")
 
-		var expressions map[string]*tfjson.Expression
-
-		if r.RSO.Configs[configId] != nil {
-			// If Resource
-			if r.RSO.Configs[configId].ResourceConfig != nil {
-				expressions = r.RSO.Configs[configId].ResourceConfig.Expressions
-				// If Module
-			} else if r.RSO.Configs[configId].ModuleConfig != nil {
-				expressions = r.RSO.Configs[configId].ModuleConfig.Expressions
-				// If Output
-			} else if r.RSO.Configs[configId].OutputConfig != nil {
-				expressions = make(map[string]*tfjson.Expression)
-				expressions["output"] = r.RSO.Configs[configId].OutputConfig.Expression
-			}
-		}
-		// fmt.Printf("%+v - %+v\n", oName, oValue)
-		for _, reValues := range expressions {
-			for _, dependsOnR := range reValues.References {
-				if !strings.HasPrefix(dependsOnR, "each.") {
-
-					/*if strings.HasPrefix(dependsOnR, "module.") {
-						id := strings.Split(dependsOnR, ".")
-						dependsOnR = fmt.Sprintf("%s.%s", id[0], id[1])
-					}*/
-
-					sourceColor := getResourceColor(re.Type)
-					targetId := dependsOnR
-					if parent != "" {
-						targetId = fmt.Sprintf("%s.%s", parent, dependsOnR)
-					}
-
-					targetColor := RESOURCE_COLOR
-
-					if strings.Contains(dependsOnR, "output.") {
-						targetColor = OUTPUT_COLOR
-					} else if strings.Contains(dependsOnR, "var.") {
-						targetColor = VARIABLE_COLOR
-					} else if strings.HasPrefix(dependsOnR, "module.") {
-						targetColor = MODULE_COLOR
-					} else if strings.Contains(dependsOnR, "data.") {
-						targetColor = DATA_COLOR
-					} else if strings.Contains(dependsOnR, "local.") {
-						targetColor = LOCAL_COLOR
-					}
-
-					// For Terraform 1.0, resource references point to specific resource attributes
-					// Skip if the target is a resource and reference points to an attribute
-					if targetColor == RESOURCE_COLOR && len(strings.Split(dependsOnR, ".")) != 2 {
-						continue
-					} else if targetColor == DATA_COLOR && len(strings.Split(dependsOnR, ".")) != 3 {
-						continue
-					}
-
-					edgeId := fmt.Sprintf("%s->%s", id, targetId)
-					emo = append(emo, edgeId)
-					edgeMap[edgeId] = Edge{
-						Data: EdgeData{
-							ID:       edgeId,
-							Source:   id,
-							Target:   targetId,
-							Gradient: fmt.Sprintf("%s %s", sourceColor, targetColor),
-						},
-						Classes: "edge",
-					}
+		for _, dependsOnR := range r.RSO.References(configId) {
+			if !strings.HasPrefix(dependsOnR, "each.") {
+
+				/*if strings.HasPrefix(dependsOnR, "module.") {
+					id := strings.Split(dependsOnR, ".")
+					dependsOnR = fmt.Sprintf("%s.%s", id[0], id[1])
+				}*/
+
+				sourceColor := getResourceColor(re.Type)
+				targetId := dependsOnR
+				if parent != "" {
+					targetId = fmt.Sprintf("%s.%s", parent, dependsOnR)
+				}
+
+				targetColor := RESOURCE_COLOR
+
+				if strings.Contains(dependsOnR, "output.") {
+					targetColor = OUTPUT_COLOR
+				} else if strings.Contains(dependsOnR, "var.") {
+					targetColor = VARIABLE_COLOR
+				} else if strings.HasPrefix(dependsOnR, "module.") {
+					targetColor = MODULE_COLOR
+				} else if strings.Contains(dependsOnR, "data.") {
+					targetColor = DATA_COLOR
+				} else if strings.Contains(dependsOnR, "local.") {
+					targetColor = LOCAL_COLOR
+				}
+
+				// For Terraform 1.0, resource references point to specific resource attributes
+				// Skip if the target is a resource and reference points to an attribute
+				if targetColor == RESOURCE_COLOR && len(strings.Split(dependsOnR, ".")) != 2 {
+					continue
+				} else if targetColor == DATA_COLOR && len(strings.Split(dependsOnR, ".")) != 3 {
+					continue
+				}
+
+				edgeId := fmt.Sprintf("%s->%s", id, targetId)
+				emo = append(emo, edgeId)
+				edgeMap[edgeId] = Edge{
+					Data: EdgeData{
+						ID:       edgeId,
+						Source:   id,
+						Target:   targetId,
+						Gradient: fmt.Sprintf("%s %s", sourceColor, targetColor),
+					},
+					Classes: "edge",
 				}
 			}
 		}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// GraphFilter selects a subgraph of the Graph and collapses modules, see Graph.Filter.
+// It is parsed from the query parameters of /api/graph and shared with -genImage and -standalone.
+type GraphFilter struct {
+	Modules     []string // Module addresses, nested modules included, e.g. module.network
+	Types       []string // Resource types, e.g. aws_instance
+	Providers   []string // Local provider names, e.g. aws
+	Actions     []Action // Change actions, e.g. create, delete
+	Address     string   // Glob on the node address with * as the only wildcard, e.g. module.*.aws_instance.*
+	ChangedOnly bool     // Only resources with changes other than no-op
+	Depth       int      // Maximum module nesting depth, 0 is the root module, -1 for no limit
+	Neighbors   int      // Also keep the nodes up to Neighbors edges away from the matching nodes
+	// Collapse the modules at this depth into summary nodes, -1 to show all modules expanded
+	CollapseDepth int
+}
+
+// ParseGraphFilter reads a GraphFilter from query parameters.
+// List parameters are comma-separated or repeated, e.g. action=create,delete.
+func ParseGraphFilter(query url.Values) (GraphFilter, error) {
+	f := GraphFilter{Depth: -1, CollapseDepth: -1}
+
+	list := func(key string) []string {
+		values := []string{}
+		for _, value := range query[key] {
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					values = append(values, v)
+				}
+			}
+		}
+		return values
+	}
+
+	for _, module := range list("module") {
+		root, module := splitNamespace(module)
+		if !strings.HasPrefix(module, "module.") {
+			module = fmt.Sprintf("module.%s", module)
+		}
+		if root != "" {
+			module = namespace(root, module)
+		}
+		f.Modules = append(f.Modules, module)
+	}
+	f.Types = list("type")
+	f.Providers = list("provider")
+	for _, action := range list("action") {
+		switch a := Action(action); a {
+		case ActionCreate, ActionRead, ActionUpdate, ActionDelete, ActionReplace, ActionNoop:
+			f.Actions = append(f.Actions, a)
+		default:
+			return f, fmt.Errorf("invalid action %q (available: create, read, update, delete, replace, no-op)", action)
+		}
+	}
+
+	f.Address = query.Get("address")
+
+	if v := query.Get("changedOnly"); v != "" {
+		changedOnly, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid value %q for changedOnly", v)
+		}
+		f.ChangedOnly = changedOnly
+	}
+
+	for _, param := range []struct {
+		key   string
+		value *int
+	}{{"depth", &f.Depth}, {"neighbors", &f.Neighbors}, {"collapseDepth", &f.CollapseDepth}} {
+		if v := query.Get(param.key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return f, fmt.Errorf("invalid value %q for %s", v, param.key)
+			}
+			*param.value = n
+		}
+	}
+
+	return f, nil
+}
+
+// selects reports whether the filter selects nodes, otherwise it keeps the whole graph
+func (f GraphFilter) selects() bool {
+	return len(f.Modules) > 0 || len(f.Types) > 0 || len(f.Providers) > 0 || len(f.Actions) > 0 ||
+		f.Address != "" || f.ChangedOnly || f.Depth >= 0
+}
+
+// graphIndex looks up the nodes of a Graph by ID
+type graphIndex map[string]Node
+
+func newGraphIndex(g Graph) graphIndex {
+	nodes := make(graphIndex, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes[n.Data.ID] = n
+	}
+	return nodes
+}
+
+// module returns the closest module of a node, or the node if it is a module,
+// and the number of modules the node is nested in
+func (nodes graphIndex) module(id string) (string, int) {
+	module, depth := "", 0
+	for n, ok := nodes[id]; ok; n, ok = nodes[n.Data.Parent] {
+		if n.Data.Type == ResourceTypeModule {
+			if module == "" {
+				module = n.Data.ID
+			}
+			if n.Data.ID != id {
+				depth++
+			}
+		}
+	}
+	return module, depth
+}
+
+// Module output references, e.g. module.network.vpc_id or module.app["a"].url
+var moduleOutputRef = regexp.MustCompile(`^(.*module\.[^.\[]+)(\[[^\]]*\])?\.([^.\[]+)$`)
+
+// resolve returns the node an edge points to, or "" if there is none. Edge targets can be
+// resource instances, e.g. aws_instance.web[0], and module outputs, e.g. module.network.vpc_id,
+// which resolve to the resource and the output node. Other targets must match a node exactly.
+func (nodes graphIndex) resolve(id string) string {
+	if _, ok := nodes[id]; ok {
+		return id
+	}
+	if i := strings.LastIndex(id, "["); i > 0 && strings.HasSuffix(id, "]") {
+		if _, ok := nodes[id[:i]]; ok {
+			return id[:i]
+		}
+	}
+	if m := moduleOutputRef.FindStringSubmatch(id); m != nil {
+		for _, candidate := range []string{m[1] + m[2] + ".output." + m[3], m[1] + ".output." + m[3]} {
+			if _, ok := nodes[candidate]; ok {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+// Filter returns the subgraph of the nodes matching f and their neighbors, with the modules
+// at f.CollapseDepth collapsed. The ancestors of kept nodes are kept as compound parents,
+// edges are kept if both ends are.
+func (g Graph) Filter(f GraphFilter) Graph {
+	if f.selects() {
+		g = g.filter(f)
+	}
+	return g.Collapse(f.CollapseDepth)
+}
+
+func (g Graph) filter(f GraphFilter) Graph {
+	nodes := newGraphIndex(g)
+
+	keep := map[string]bool{}
+	for _, n := range g.Nodes {
+		if f.matches(n, nodes) {
+			keep[n.Data.ID] = true
+		}
+	}
+
+	for i := 0; i < f.Neighbors; i++ {
+		added := []string{}
+		for _, e := range g.Edges {
+			source, target := nodes.resolve(e.Data.Source), nodes.resolve(e.Data.Target)
+			if source == "" || target == "" {
+				continue
+			}
+			if keep[source] && !keep[target] {
+				added = append(added, target)
+			} else if keep[target] && !keep[source] {
+				added = append(added, source)
+			}
+		}
+		for _, id := range added {
+			if _, depth := nodes.module(id); f.Depth < 0 || depth <= f.Depth {
+				keep[id] = true
+			}
+		}
+	}
+
+	for id := range keep {
+		for parent := nodes[id].Data.Parent; parent != "" && !keep[parent]; parent = nodes[parent].Data.Parent {
+			keep[parent] = true
+		}
+	}
+
+	filtered := Graph{Nodes: []Node{}, Edges: []Edge{}}
+	for _, n := range g.Nodes {
+		if keep[n.Data.ID] {
+			filtered.Nodes = append(filtered.Nodes, n)
+		}
+	}
+	for _, e := range g.Edges {
+		if keep[nodes.resolve(e.Data.Source)] && keep[nodes.resolve(e.Data.Target)] {
+			filtered.Edges = append(filtered.Edges, e)
+		}
+	}
+
+	return filtered
+}
+
+// matches reports whether a node matches all criteria of the filter
+func (f GraphFilter) matches(n Node, nodes graphIndex) bool {
+	if n.Data.Type == "basename" {
+		return false
+	}
+
+	// IDs of combined roots are namespaced, e.g. network::module.vpc. Module and address
+	// filters match with the root, selecting one root, or without, selecting all roots.
+	module, depth := nodes.module(n.Data.ID)
+	_, rootModule := splitNamespace(module)
+	_, rootID := splitNamespace(n.Data.ID)
+	if f.Depth >= 0 && depth > f.Depth {
+		return false
+	}
+	if len(f.Modules) > 0 && !slices.ContainsFunc(f.Modules, func(m string) bool {
+		return inModule(module, m) || inModule(rootModule, m)
+	}) {
+		return false
+	}
+	if len(f.Types) > 0 && !slices.ContainsFunc(f.Types, func(t string) bool { return n.Data.ResourceType == t }) {
+		return false
+	}
+	if len(f.Providers) > 0 && !slices.ContainsFunc(f.Providers, func(p string) bool { return n.Data.Provider == p }) {
+		return false
+	}
+	if len(f.Actions) > 0 && !slices.ContainsFunc(f.Actions, func(a Action) bool { return n.Data.Change == string(a) }) {
+		return false
+	}
+	if f.ChangedOnly && (n.Data.Change == "" || n.Data.Change == string(ActionNoop)) {
+		return false
+	}
+	if f.Address != "" {
+		if !matchAddress(f.Address, n.Data.ID) && !matchAddress(f.Address, rootID) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// inModule reports whether module is m or nested in m
+func inModule(module string, m string) bool {
+	return module == m || strings.HasPrefix(module, m+".") || strings.HasPrefix(module, m+"[")
+}
+
+// Collapse replaces the contents of the modules at depth with a summary of their resources and changes.
+// Edges into collapsed modules are merged, their Weight is the number of merged edges.
+func (g Graph) Collapse(depth int) Graph {
+	if depth < 0 {
+		return g
+	}
+	nodes := newGraphIndex(g)
+
+	// summaries are the modules at depth, collapsedInto the summary containing a node
+	summaries := map[string]*NodeData{}
+	for _, n := range g.Nodes {
+		if _, d := nodes.module(n.Data.ID); n.Data.Type == ResourceTypeModule && d == depth {
+			summaries[n.Data.ID] = &NodeData{Changes: map[Action]int{}}
+		}
+	}
+	collapsedInto := map[string]string{}
+	for _, n := range g.Nodes {
+		for parent := n.Data.Parent; parent != ""; parent = nodes[parent].Data.Parent {
+			if summaries[parent] != nil {
+				collapsedInto[n.Data.ID] = parent
+				break
+			}
+		}
+
+		summary := summaries[collapsedInto[n.Data.ID]]
+		if summary == nil || !strings.HasPrefix(n.Classes, "resource-name") {
+			continue
+		}
+		summary.Resources++
+		if n.Data.Change != "" && n.Data.Change != string(ActionNoop) {
+			summary.Changes[Action(n.Data.Change)]++
+		}
+	}
+
+	collapsed := Graph{Nodes: []Node{}, Edges: []Edge{}}
+	for _, n := range g.Nodes {
+		if collapsedInto[n.Data.ID] != "" {
+			continue
+		}
+		if summary := summaries[n.Data.ID]; summary != nil {
+			n.Data.Collapsed = true
+			n.Data.Resources = summary.Resources
+			if len(summary.Changes) > 0 {
+				n.Data.Changes = summary.Changes
+			}
+			n.Data.Label = collapsedLabel(n.Data.Label, summary.Resources, summary.Changes)
+			n.Classes = fmt.Sprintf("%s collapsed", n.Classes)
+		}
+		collapsed.Nodes = append(collapsed.Nodes, n)
+	}
+
+	// representative is the node an edge end is drawn to, the summary for collapsed nodes
+	representative := func(id string) string {
+		id = nodes.resolve(id)
+		if into := collapsedInto[id]; into != "" {
+			return into
+		}
+		return id
+	}
+
+	merged := map[string]int{}
+	for _, e := range g.Edges {
+		source, target := representative(e.Data.Source), representative(e.Data.Target)
+		if source == "" || target == "" {
+			continue
+		}
+		if summaries[source] == nil && summaries[target] == nil {
+			collapsed.Edges = append(collapsed.Edges, e)
+			continue
+		}
+		// Edges within a collapsed module
+		if source == target {
+			continue
+		}
+
+		id := fmt.Sprintf("%s->%s", source, target)
+		if i, ok := merged[id]; ok {
+			collapsed.Edges[i].Data.Weight++
+			continue
+		}
+		merged[id] = len(collapsed.Edges)
+		e.Data.ID, e.Data.Source, e.Data.Target, e.Data.Weight = id, source, target, 1
+		collapsed.Edges = append(collapsed.Edges, e)
+	}
+
+	return collapsed
+}
+
+// collapsedLabel returns the label of a collapsed module, e.g. "eks: 42 resources, +3 ~5 -1"
+func collapsedLabel(label string, resources int, changes map[Action]int) string {
+	label = fmt.Sprintf("%s: %d resources", label, resources)
+	parts := []string{}
+	for _, a := range summaryActions {
+		if changes[a] > 0 {
+			parts = append(parts, fmt.Sprintf("%s%d", actionSymbol(a), changes[a]))
+		}
+	}
+	if len(parts) > 0 {
+		label = fmt.Sprintf("%s, %s", label, strings.Join(parts, " "))
+	}
+	return label
+}
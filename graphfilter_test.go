@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// goldenGraph loads the graph of example/random-test, see TestGoldenRandomTest
+func goldenGraph(t *testing.T) Graph {
+	t.Helper()
+	content, err := os.ReadFile("testdata/random-test/graph.golden.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := Graph{}
+	if err := json.Unmarshal(content, &g); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestGraphIndexResolve(t *testing.T) {
+	nodes := newGraphIndex(goldenGraph(t))
+
+	tests := map[string]string{
+		"random_pet.dogs[0]":                        "random_pet.dogs[0]",
+		"random_pet.cow[0]":                         "random_pet.cow",
+		"module.random_cat.random_name":             "module.random_cat.output.random_name",
+		"module.random_cat[0].random_name":          "module.random_cat.output.random_name",
+		"module.random_cat.missing":                 "",
+		"random_pet.dogs.random_integer.pet_length": "",
+		"random_pet.unknown":                        "",
+	}
+	for id, want := range tests {
+		if got := nodes.resolve(id); got != want {
+			t.Errorf("resolve(%s) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestGraphFilterDropsUnresolvedEdges(t *testing.T) {
+	// random_pet.dogs[0] has an edge to random_pet.dogs.random_integer.pet_length, which is no node
+	f := GraphFilter{Address: "random_pet.dogs[0]", Neighbors: 1, Depth: -1, CollapseDepth: -1}
+	filtered := goldenGraph(t).Filter(f)
+
+	if len(filtered.Edges) != 0 {
+		t.Errorf("edges = %+v, want none", filtered.Edges)
+	}
+	for _, n := range filtered.Nodes {
+		if n.Data.ID == "random_integer.pet_length" {
+			t.Errorf("filtered graph has neighbor %s of an unresolved edge", n.Data.ID)
+		}
+	}
+}
+
+// nodeIDs returns the IDs of the nodes, in graph order
+func nodeIDs(g Graph) []string {
+	ids := []string{}
+	for _, n := range g.Nodes {
+		ids = append(ids, n.Data.ID)
+	}
+	return ids
+}
+
+// edgeIDs returns the IDs of the edges with their weight, in graph order
+func edgeIDs(g Graph) []string {
+	ids := []string{}
+	for _, e := range g.Edges {
+		ids = append(ids, fmt.Sprintf("%s (%d)", e.Data.ID, e.Data.Weight))
+	}
+	return ids
+}
+
+func TestGraphFilterChangedOnly(t *testing.T) {
+	filtered := goldenGraph(t).Filter(GraphFilter{ChangedOnly: true, Depth: -1, CollapseDepth: -1})
+	ids := nodeIDs(filtered)
+
+	// Changed resources and data sources with their parents, e.g. random_pet.dogs of random_pet.dogs[2]
+	for _, id := range []string{"data.http.terraform_metadata", "random_pet.cow", "random_pet.dogs[2]", "random_pet.dogs", "random_pet {main.tf}", "module.random_cat", "main.tf"} {
+		if !slices.Contains(ids, id) {
+			t.Errorf("nodes = %q, want %s", ids, id)
+		}
+	}
+	for _, id := range []string{"random_integer.pet_length", "random_integer {main.tf}", "local.random_dog", "var.max_length", "output.random_cow_name", "module.random_cat.output.random_name"} {
+		if slices.Contains(ids, id) {
+			t.Errorf("nodes = %q, want no %s", ids, id)
+		}
+	}
+
+	// Edges to the unchanged random_integer.pet_length are dropped
+	want := []string{"module.random_cat.random_pet.pet->module.random_cat.random_integer.pet_length (0)"}
+	if got := edgeIDs(filtered); !slices.Equal(got, want) {
+		t.Errorf("edges = %q, want %q", got, want)
+	}
+}
+
+func TestGraphFilterModule(t *testing.T) {
+	moduleNodes := []string{
+		"example/random-test",
+		"main.tf",
+		"module.random_cat",
+		"module.random_cat.main.tf",
+		"module.random_cat.output.random_name",
+		"module.random_cat.random_integer {main.tf}",
+		"module.random_cat.random_integer.pet_length",
+		"module.random_cat.random_pet {main.tf}",
+		"module.random_cat.random_pet.pet",
+		"module.random_cat.var.max_length",
+	}
+	moduleEdges := []string{
+		"module.random_cat.output.random_name->module.random_cat.random_pet.pet (0)",
+		"module.random_cat.random_integer.pet_length->module.random_cat.var.max_length (0)",
+		"module.random_cat.random_pet.pet->module.random_cat.random_integer.pet_length (0)",
+	}
+
+	tests := []struct {
+		neighbors int
+		nodes     []string
+		edges     []string
+	}{
+		{neighbors: 0, nodes: moduleNodes, edges: moduleEdges},
+		// output.random_cat_name references the module and its output random_name
+		{
+			neighbors: 1,
+			nodes:     append(slices.Clone(moduleNodes), "output.random_cat_name"),
+			edges: append(slices.Clone(moduleEdges),
+				"output.random_cat_name->module.random_cat.random_name (0)",
+				"output.random_cat_name->module.random_cat (0)"),
+		},
+	}
+
+	for _, tt := range tests {
+		f := GraphFilter{Modules: []string{"module.random_cat"}, Neighbors: tt.neighbors, Depth: -1, CollapseDepth: -1}
+		filtered := goldenGraph(t).Filter(f)
+		if got := nodeIDs(filtered); !slices.Equal(got, tt.nodes) {
+			t.Errorf("neighbors %d: nodes = %q, want %q", tt.neighbors, got, tt.nodes)
+		}
+		if got := edgeIDs(filtered); !slices.Equal(got, tt.edges) {
+			t.Errorf("neighbors %d: edges = %q, want %q", tt.neighbors, got, tt.edges)
+		}
+	}
+}
+
+func TestGraphCollapse(t *testing.T) {
+	collapsed := goldenGraph(t).Filter(GraphFilter{Depth: -1, CollapseDepth: 0})
+
+	var summary *NodeData
+	for i, n := range collapsed.Nodes {
+		if strings.HasPrefix(n.Data.ID, "module.random_cat.") {
+			t.Errorf("node %s of the collapsed module is kept", n.Data.ID)
+		}
+		if n.Data.ID == "module.random_cat" {
+			summary = &collapsed.Nodes[i].Data
+		}
+	}
+	if summary == nil {
+		t.Fatal("module.random_cat is missing")
+	}
+	if !summary.Collapsed || summary.Resources != 2 || summary.Changes[ActionCreate] != 2 || summary.Label != "random_cat: 2 resources, +2" {
+		t.Errorf("module.random_cat = %+v, want 2 created resources", *summary)
+	}
+
+	// Both edges of output.random_cat_name into the module are merged, edges within it dropped
+	edges := edgeIDs(collapsed)
+	if !slices.Contains(edges, "output.random_cat_name->module.random_cat (2)") {
+		t.Errorf("edges = %q, want output.random_cat_name->module.random_cat with weight 2", edges)
+	}
+	for _, e := range collapsed.Edges {
+		if strings.HasPrefix(e.Data.Source, "module.random_cat.") || strings.HasPrefix(e.Data.Target, "module.random_cat.") {
+			t.Errorf("edge %s into the collapsed module is kept", e.Data.ID)
+		}
+	}
+	if len(edges) != 10 {
+		t.Errorf("edges = %q, want the 9 edges outside the module and the merged one", edges)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+)
+
+// initialized reports whether dir has an initialized .terraform directory, i.e. modules.json
+// lists every module call, nested ones included, with the configured source and a version
+// matching the constraint, and every required provider is installed. Otherwise it returns what is missing.
+func initialized(dir string) (bool, string) {
+	dotTerraform := filepath.Join(dir, ".terraform")
+	if _, err := os.Stat(dotTerraform); err != nil {
+		return false, "no .terraform directory"
+	}
+
+	module, diags := tfconfig.LoadModule(dir)
+	if diags.HasErrors() {
+		return false, diags.Error()
+	}
+
+	installed := map[string]ModuleLocation{}
+	if len(module.ModuleCalls) > 0 {
+		var err error
+		installed, err = readModuleManifest(filepath.Join(dotTerraform, "modules", "modules.json"))
+		if os.IsNotExist(err) {
+			return false, "no .terraform/modules/modules.json"
+		} else if err != nil {
+			return false, fmt.Sprintf("invalid modules.json: %s", err)
+		}
+	}
+
+	if missing := moduleInstalled(dir, "", module, installed); missing != "" {
+		return false, missing
+	}
+	return true, ""
+}
+
+// moduleInstalled checks the providers and module calls of a module recursively and returns what is missing
+func moduleInstalled(dir string, key string, module *tfconfig.Module, installed map[string]ModuleLocation) string {
+	dotTerraform := filepath.Join(dir, ".terraform")
+
+	for _, name := range sortedKeys(module.RequiredProviders) {
+		source := module.RequiredProviders[name].Source
+		if !providerInstalled(dotTerraform, name, source) {
+			return fmt.Sprintf("provider %s is not installed", name)
+		}
+	}
+
+	for _, name := range sortedKeys(module.ModuleCalls) {
+		call := module.ModuleCalls[name]
+		childKey := name
+		if key != "" {
+			childKey = fmt.Sprintf("%s.%s", key, name)
+		}
+
+		loc, ok := installed[childKey]
+		if !ok {
+			return fmt.Sprintf("module %s is not installed", childKey)
+		}
+		if !sameModuleSource(call.Source, loc.Source) {
+			return fmt.Sprintf("module %s is installed from %s instead of %s", childKey, loc.Source, call.Source)
+		}
+		if call.Version != "" && loc.Version != "" {
+			constraints, err := version.NewConstraint(call.Version)
+			v, verr := version.NewVersion(loc.Version)
+			if err == nil && verr == nil && !constraints.Check(v) {
+				return fmt.Sprintf("module %s is installed in version %s, which does not match %q", childKey, loc.Version, call.Version)
+			}
+		}
+
+		child, diags := tfconfig.LoadModule(filepath.Join(dir, loc.Dir))
+		if diags.HasErrors() {
+			return fmt.Sprintf("module %s: %s", childKey, diags.Error())
+		}
+		if missing := moduleInstalled(dir, childKey, child, installed); missing != "" {
+			return missing
+		}
+	}
+
+	return ""
+}
+
+// sameModuleSource compares a configured module source with the one in modules.json,
+// which has the registry host of registry sources, e.g. registry.terraform.io/hashicorp/consul/aws
+func sameModuleSource(configured string, installed string) bool {
+	if configured == installed {
+		return true
+	}
+	address, _ := splitSubdir(configured)
+	if !registrySource.MatchString(address) {
+		return false
+	}
+	for _, host := range []string{"registry.terraform.io", "registry.opentofu.org"} {
+		if installed == fmt.Sprintf("%s/%s", host, configured) {
+			return true
+		}
+	}
+	return false
+}
+
+// providerInstalled looks for a provider in .terraform/providers/<host>/<namespace>/<type>
+// (Terraform >= 0.14) or .terraform/plugins/<host>/<namespace>/<type> (Terraform 0.13)
+func providerInstalled(dotTerraform string, name string, source string) bool {
+	namespace, providerType := "hashicorp", name
+	if parts := strings.Split(source, "/"); len(parts) >= 2 {
+		namespace, providerType = parts[len(parts)-2], parts[len(parts)-1]
+	}
+
+	for _, dir := range []string{"providers", "plugins"} {
+		matches, _ := filepath.Glob(filepath.Join(dotTerraform, dir, "*", namespace, providerType, "*"))
+		if len(matches) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInitialized(t *testing.T) {
+	config := map[string]string{
+		"main.tf":          "module \"app\" {\n  source = \"./app\"\n}\n",
+		"app/main.tf":      "module \"vpc\" {\n  source  = \"terraform-aws-modules/vpc/aws\"\n  version = \"~> 5.0\"\n}\n",
+		"vpc/variables.tf": "variable \"cidr\" {\n  default = \"10.0.0.0/16\"\n}\n",
+	}
+
+	tests := []struct {
+		name     string
+		manifest string
+		missing  string
+	}{
+		{
+			name:    "no modules.json",
+			missing: "no .terraform/modules/modules.json",
+		},
+		{
+			name:     "nested module missing",
+			manifest: `{"Modules":[{"Key":"","Source":"","Dir":"."},{"Key":"app","Source":"./app","Dir":"app"}]}`,
+			missing:  "module app.vpc is not installed",
+		},
+		{
+			name:     "source changed",
+			manifest: `{"Modules":[{"Key":"app","Source":"./app","Dir":"app"},{"Key":"app.vpc","Source":"registry.terraform.io/acme/vpc/aws","Version":"5.1.0","Dir":"vpc"}]}`,
+			missing:  "module app.vpc is installed from registry.terraform.io/acme/vpc/aws",
+		},
+		{
+			name:     "version outside constraint",
+			manifest: `{"Modules":[{"Key":"app","Source":"./app","Dir":"app"},{"Key":"app.vpc","Source":"registry.terraform.io/terraform-aws-modules/vpc/aws","Version":"4.0.2","Dir":"vpc"}]}`,
+			missing:  "module app.vpc is installed in version 4.0.2",
+		},
+		{
+			name:     "initialized",
+			manifest: `{"Modules":[{"Key":"app","Source":"./app","Dir":"app"},{"Key":"app.vpc","Source":"registry.terraform.io/terraform-aws-modules/vpc/aws","Version":"5.1.0","Dir":"vpc"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, config)
+			if err := os.MkdirAll(filepath.Join(dir, ".terraform", "modules"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.manifest != "" {
+				writeFiles(t, dir, map[string]string{".terraform/modules/modules.json": tt.manifest})
+			}
+
+			ok, missing := initialized(dir)
+			if tt.missing == "" {
+				if !ok {
+					t.Errorf("initialized = false (%s), want true", missing)
+				}
+				return
+			}
+			if ok || !strings.HasPrefix(missing, tt.missing) {
+				t.Errorf("initialized = %v, %q, want false, %q", ok, missing, tt.missing)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+const lintUnusedClass = "lint-unused"
+
+var lintRules = []FindingRule{
+	{ID: "unused-variable", Description: "Variable is not referenced by any expression", Severity: SeverityLow, Category: "lint"},
+	{ID: "unused-local", Description: "Local value is never referenced", Severity: SeverityLow, Category: "lint"},
+	{ID: "unused-module-output", Description: "Output of a child module is not consumed by its parent", Severity: SeverityLow, Category: "lint"},
+	{ID: "default-always-overridden", Description: "Variable default is overridden by every module call", Severity: SeverityInfo, Category: "lint"},
+}
+
+// moduleReferences collects the references made by the expressions of a module
+type moduleReferences struct {
+	refs map[string]bool
+	// Module calls referenced as a whole object, e.g. module.network
+	wholeModules map[string]bool
+}
+
+func newModuleReferences() *moduleReferences {
+	return &moduleReferences{
+		refs:         map[string]bool{},
+		wholeModules: map[string]bool{},
+	}
+}
+
+func (mr *moduleReferences) add(references []string) {
+
+	normalized := make([]string, 0, len(references))
+	for _, ref := range references {
+		ref = matchBrackets.ReplaceAllString(ref, "")
+		normalized = append(normalized, ref)
+		mr.refs[ref] = true
+	}
+
+	// References always contain the module call itself, e.g. module.a.out and module.a.
+	// The whole module is used only if no output of it is referenced.
+	for _, ref := range normalized {
+		parts := strings.Split(ref, ".")
+		if len(parts) != 2 || parts[0] != "module" {
+			continue
+		}
+		whole := true
+		for _, other := range normalized {
+			if strings.HasPrefix(other, ref+".") {
+				whole = false
+				break
+			}
+		}
+		if whole {
+			mr.wholeModules[ref] = true
+		}
+	}
+}
+
+func (mr *moduleReferences) addExpression(exp *tfjson.Expression) {
+	if exp == nil {
+		return
+	}
+	mr.add(exp.References)
+	for _, block := range exp.NestedBlocks {
+		mr.addExpressions(block)
+	}
+}
+
+func (mr *moduleReferences) addExpressions(expressions map[string]*tfjson.Expression) {
+	for _, exp := range expressions {
+		mr.addExpression(exp)
+	}
+}
+
+// GenerateLint reports unused variables, locals and module outputs,
+// and variable defaults that every module call overrides
+func (r *rover) GenerateLint() {
+	log.Println("Analyzing configuration...")
+
+	r.Lint = []Finding{}
+	configs := r.RSO.Configs
+
+	// Module configurations, keyed by config address ("" for the root module)
+	modules := []string{}
+	for key, config := range configs {
+		if config.ModuleConfig != nil && config.ModuleConfig.Module != nil {
+			modules = append(modules, key)
+		}
+	}
+	sort.Strings(modules)
+
+	for _, key := range modules {
+		config := configs[key]
+		module := config.ModuleConfig.Module
+		refs := r.moduleReferences(key, config)
+
+		prefix := ""
+		if key != "" {
+			prefix = key + "."
+		}
+
+		for _, name := range sortedKeys(module.Variables) {
+			if !refs.refs["var."+name] {
+				r.addLint("unused-variable", fmt.Sprintf("%svar.%s", prefix, name),
+					fmt.Sprintf("Variable %q is declared but not referenced", name))
+			}
+		}
+
+		for _, name := range sortedKeys(config.Locals) {
+			if !refs.refs["local."+name] {
+				l := config.Locals[name]
+				r.addLint("unused-local", fmt.Sprintf("%slocal.%s", prefix, name),
+					fmt.Sprintf("Local value %q is never referenced", name))
+				r.Lint[len(r.Lint)-1].File = l.Filename
+				r.Lint[len(r.Lint)-1].Line = l.Line
+			}
+		}
+
+		for _, callName := range sortedKeys(module.ModuleCalls) {
+			call := module.ModuleCalls[callName]
+			if call.Module == nil || refs.wholeModules["module."+callName] {
+				continue
+			}
+			for _, output := range sortedKeys(call.Module.Outputs) {
+				if !refs.refs[fmt.Sprintf("module.%s.%s", callName, output)] {
+					r.addLint("unused-module-output", fmt.Sprintf("%smodule.%s.output.%s", prefix, callName, output),
+						fmt.Sprintf("Output %q of module %q is not used by its parent", output, callName))
+				}
+			}
+		}
+	}
+
+	r.lintOverriddenDefaults(modules)
+
+	log.Printf("Found %d lint findings.", len(r.Lint))
+
+	// Lint findings are kept apart from the policy findings, so they don't count
+	// towards -failOnSeverity, the SARIF and JUnit exports add them with the lint rules
+	r.locateFindings(r.Lint)
+
+	unused := map[string]bool{}
+	for _, f := range r.Lint {
+		if f.RuleID != "default-always-overridden" {
+			unused[f.Address] = true
+		}
+	}
+	for i, n := range r.Graph.Nodes {
+		if unused[n.Data.ID] {
+			r.Graph.Nodes[i].Classes = strings.TrimSpace(fmt.Sprintf("%s %s", n.Classes, lintUnusedClass))
+		}
+	}
+}
+
+// moduleReferences collects every reference made inside a module
+func (r *rover) moduleReferences(key string, config *ConfigOverview) *moduleReferences {
+	refs := newModuleReferences()
+	module := config.ModuleConfig.Module
+
+	for _, resource := range module.Resources {
+		refs.addExpressions(resource.Expressions)
+		refs.addExpression(resource.CountExpression)
+		refs.addExpression(resource.ForEachExpression)
+		refs.add(resource.DependsOn)
+	}
+
+	for _, call := range module.ModuleCalls {
+		refs.addExpressions(call.Expressions)
+		refs.addExpression(call.CountExpression)
+		refs.addExpression(call.ForEachExpression)
+		refs.add(call.DependsOn)
+	}
+
+	for _, output := range module.Outputs {
+		refs.addExpression(output.Expression)
+		refs.add(output.DependsOn)
+	}
+
+	for _, l := range config.Locals {
+		refs.add(l.References)
+	}
+
+	if r.Plan.Config != nil {
+		for _, provider := range r.Plan.Config.ProviderConfigs {
+			if provider.ModuleAddress == key {
+				refs.addExpressions(provider.Expressions)
+			}
+		}
+	}
+
+	return refs
+}
+
+// lintOverriddenDefaults reports variables with a default that all calls of the module set
+func (r *rover) lintOverriddenDefaults(modules []string) {
+	configs := r.RSO.Configs
+
+	// Group module calls by the directory (or source) of the called module
+	calls := map[string][]string{}
+	for _, key := range modules {
+		if key == "" {
+			continue
+		}
+		group := configs[key].ModuleConfig.Source
+		if configs[key].Module != nil {
+			group = configs[key].Module.Path
+		}
+		calls[group] = append(calls[group], key)
+	}
+
+	for _, group := range sortedKeys(calls) {
+		keys := calls[group]
+		first := configs[keys[0]]
+		if first.Module == nil {
+			continue
+		}
+
+		for _, name := range sortedKeys(first.Module.Variables) {
+			if first.Module.Variables[name].Required {
+				continue
+			}
+
+			overridden := true
+			for _, key := range keys {
+				if _, ok := configs[key].ModuleConfig.Expressions[name]; !ok {
+					overridden = false
+					break
+				}
+			}
+			if !overridden {
+				continue
+			}
+
+			for _, key := range keys {
+				r.addLint("default-always-overridden", fmt.Sprintf("%s.var.%s", key, name),
+					fmt.Sprintf("Default of variable %q is overridden by all %d call(s) of %s", name, len(keys), group))
+			}
+		}
+	}
+}
+
+func (r *rover) addLint(ruleID string, address string, message string) {
+	for _, rule := range lintRules {
+		if rule.ID == ruleID {
+			r.Lint = append(r.Lint, Finding{
+				RuleID:   ruleID,
+				Severity: rule.Severity,
+				Message:  message,
+				Address:  address,
+			})
+			return
+		}
+	}
+}
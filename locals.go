@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// Local is a named value from a locals block, which tfconfig does not expose
+type Local struct {
+	Name       string   `json:"name"`
+	Filename   string   `json:"filename"`
+	Line       int      `json:"line"`
+	Expression string   `json:"expression"`
+	References []string `json:"references,omitempty"`
+}
+
+// loadLocals parses the locals blocks of all configuration files in a module directory
+func loadLocals(dir string) map[string]*Local {
+	locals := make(map[string]*Local)
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return locals
+	}
+
+	parser := hclparse.NewParser()
+
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".tf") {
+			continue
+		}
+
+		filename := filepath.Join(dir, f.Name())
+		file, diags := parser.ParseHCLFile(filename)
+		if diags.HasErrors() {
+			continue
+		}
+
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+
+		for _, block := range body.Blocks {
+			if block.Type != "locals" {
+				continue
+			}
+
+			for name, attr := range block.Body.Attributes {
+				locals[name] = &Local{
+					Name:       name,
+					Filename:   filename,
+					Line:       attr.SrcRange.Start.Line,
+					Expression: string(attr.Expr.Range().SliceBytes(file.Bytes)),
+					References: expressionReferences(attr.Expr),
+				}
+			}
+		}
+	}
+
+	return locals
+}
+
+// expressionReferences lists the references of an expression in the format
+// of the plan's configuration, e.g. random_pet.dog.id and random_pet.dog
+func expressionReferences(expr hcl.Expression) []string {
+	refs := map[string]bool{}
+
+	for _, traversal := range expr.Variables() {
+		parts := []string{traversal.RootName()}
+		for _, step := range traversal[1:] {
+			attr, ok := step.(hcl.TraverseAttr)
+			if !ok {
+				break
+			}
+			parts = append(parts, attr.Name)
+		}
+
+		if len(parts) == 1 {
+			refs[parts[0]] = true
+			continue
+		}
+		for i := len(parts); i >= 2; i-- {
+			refs[strings.Join(parts[:i], ".")] = true
+		}
+	}
+
+	references := make([]string, 0, len(refs))
+	for ref := range refs {
+		references = append(references, ref)
+	}
+	sort.Strings(references)
+
+	return references
+}
+
+// localExpressions wraps the references of a local as plan expressions, for edge generation
+func localExpressions(l *Local) map[string]*tfjson.Expression {
+	return map[string]*tfjson.Expression{
+		"local": {
+			ExpressionData: &tfjson.ExpressionData{
+				References: l.References,
+			},
+		},
+	}
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	tfjson "github.com/hashicorp/terraform-json"
+	"io"
 	"io/fs"
 	"log"
+	"net/url"
+	"os"
+	"os/signal"
 	"rover/config"
 	"strings"
+	"syscall"
 )
 
 var TRUE = true
@@ -16,30 +22,82 @@ var TRUE = true
 var frontend embed.FS
 
 type rover struct {
-	Name             string
-	WorkingDir       string
-	TfPath           string
-	TfVarsFiles      []string
-	TfVars           []string
-	TfBackendConfigs []string
-	PlanPath         string
-	PlanJSONPath     string
-	WorkspaceName    string
-	TFCOrgName       string
-	TFCWorkspaceName string
-	ShowSensitive    bool
-	GenImage         bool
-	TFCNewRun        bool
-	Plan             *tfjson.Plan
-	RSO              *ResourcesOverview
-	Map              *Map
-	Graph            Graph
+	Name               string
+	WorkingDir         string
+	TfPath             string
+	TfVarsFiles        []string
+	TfVars             []string
+	TfBackendConfigs   []string
+	Targets            []string
+	Replace            []string
+	Destroy            bool
+	Refresh            bool
+	RefreshOnly        bool
+	Lock               bool
+	Parallelism        int
+	SkipInit           bool
+	InitUpgrade        bool
+	PluginDirs         []string
+	PlanPath           string
+	PlanJSONPath       string
+	WorkspaceName      string
+	TFCOrgName         string
+	TFCWorkspaceName   string
+	ShowSensitive      bool
+	ProviderSchema     bool
+	ProviderSchemaPath string
+	GenImage           bool
+	Image              ImageOptions
+	TFCNewRun          bool
+	PolicyFiles        []string
+	EditorURL          string
+	GitBase            string
+	Roots              []string
+	TerragruntDir      string
+	TerragruntPath     string
+	TerragruntPlanJSON string
+	Plan               *tfjson.Plan
+	Plans              map[string]*tfjson.Plan
+	Schemas            *SchemaIndex
+	Providers          *ProviderInventory
+	Modules            *ModuleInventory
+	Meta               Meta
+	ReplacePaths       map[string][][]interface{}
+	RSO                *ResourcesOverview
+	Map                *Map
+	Graph              Graph
+	GraphFilter        GraphFilter // -graphFilter, applied to the graph of -standalone
+	Findings           []Finding
+	Lint               []Finding
+	Rules              []FindingRule
+	Git                map[string]*GitBlock
+	ConfigDiff         *ConfigDiff
+	gitRepo            *gitRepo
+	progress           *progress
+	baseRSO            *ResourcesOverview // config-diff: configuration of the first ref
+	rootName           string             // -root and -terragrunt: name of the root generated by this rover
+	cleanups           []func()           // Run by cleanup before Rover exits
 }
 
 func main() {
-	cfg := config.LoadConfig()
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	r := createRoverFromConfig(*cfg)
-	runApp(r, *cfg)
+	if r.GraphFilter, err = ParseGraphFilter(graphQuery(*cfg)); err != nil {
+		log.Fatalf("Invalid graphFilter: %s", err)
+	}
+
+	// Cancelled on SIGINT or SIGTERM, a second signal exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		log.Println("Interrupted, waiting for terraform to exit...")
+	}()
+
+	runApp(ctx, r, *cfg)
 }
 
 func createRoverFromConfig(cfg config.Config) rover {
@@ -47,32 +105,122 @@ func createRoverFromConfig(cfg config.Config) rover {
 	parsedTfVars := strings.Split(cfg.TfVars.String(), ",")
 	parsedTfBackendConfigs := strings.Split(cfg.TfBackendConfigs.String(), ",")
 	return rover{
-		Name:             cfg.Name,
-		WorkingDir:       cfg.WorkingDir,
-		TfPath:           cfg.TfPath,
-		PlanPath:         cfg.PlanPath,
-		PlanJSONPath:     cfg.PlanJSONPath,
-		ShowSensitive:    cfg.ShowSensitive,
-		GenImage:         cfg.GenImage,
-		TfVarsFiles:      parsedTfVarsFiles,
-		TfVars:           parsedTfVars,
-		TfBackendConfigs: parsedTfBackendConfigs,
-		WorkspaceName:    cfg.WorkspaceName,
-		TFCOrgName:       cfg.TFCOrgName,
-		TFCWorkspaceName: cfg.TFCWorkspaceName,
-		TFCNewRun:        cfg.TFCNewRun,
+		Name:               cfg.Name,
+		WorkingDir:         cfg.WorkingDir,
+		TfPath:             cfg.TfPath,
+		PlanPath:           cfg.PlanPath,
+		PlanJSONPath:       cfg.PlanJSONPath,
+		ShowSensitive:      cfg.ShowSensitive,
+		ProviderSchema:     cfg.ProviderSchema,
+		ProviderSchemaPath: cfg.ProviderSchemaPath,
+		GenImage:           cfg.GenImage,
+		Image:              imageOptions(cfg),
+		TfVarsFiles:        parsedTfVarsFiles,
+		TfVars:             parsedTfVars,
+		TfBackendConfigs:   parsedTfBackendConfigs,
+		Targets:            cfg.Targets,
+		Replace:            cfg.Replace,
+		Destroy:            cfg.Destroy,
+		Refresh:            cfg.Refresh,
+		RefreshOnly:        cfg.RefreshOnly,
+		Lock:               cfg.Lock,
+		Parallelism:        cfg.Parallelism,
+		SkipInit:           cfg.SkipInit,
+		InitUpgrade:        cfg.InitUpgrade,
+		PluginDirs:         cfg.PluginDirs,
+		WorkspaceName:      cfg.WorkspaceName,
+		TFCOrgName:         cfg.TFCOrgName,
+		TFCWorkspaceName:   cfg.TFCWorkspaceName,
+		TFCNewRun:          cfg.TFCNewRun,
+		PolicyFiles:        cfg.PolicyFiles,
+		Meta:               Meta{RoverVersion: cfg.Version},
+		EditorURL:          cfg.EditorURL,
+		GitBase:            cfg.GitBase,
+		Roots:              cfg.Roots,
+		TerragruntDir:      cfg.TerragruntDir,
+		TerragruntPath:     cfg.TerragruntPath,
+		TerragruntPlanJSON: cfg.TerragruntPlanJSON,
 	}
 }
 
-func runApp(r rover, cfg config.Config) {
+func runApp(ctx context.Context, r rover, cfg config.Config) {
 	log.Println("Starting Rover...")
-	// Generate assets
-	var err = r.generateAssets()
+	r.progress = newProgress()
+
+	// Serve the progress at /api/progress while the assets are generated,
+	// unless the command exits after generating them
+	if r.serves(cfg) && !cfg.GenImage {
+		log.SetOutput(io.MultiWriter(os.Stderr, r.progress))
+		generated := make(chan struct{})
+		go func() {
+			defer close(generated)
+			err := r.generate(ctx, cfg)
+			if err != nil {
+				log.Printf("Unable to generate assets: %s", err)
+			} else if r.failsOnFindings(cfg.FailOnSeverity) {
+				log.Printf("Plan has findings with severity %s or higher, see /api/findings", cfg.FailOnSeverity)
+			}
+			r.progress.finish(err)
+		}()
+
+		err := r.startServer(ctx, cfg.IPPort)
+		if err != nil {
+			log.Printf("Could not start server: %s\n", err.Error())
+		}
+
+		// Let terraform release locks and remove temporary files
+		<-generated
+		r.cleanup()
+		if err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	err := r.generate(ctx, cfg)
+	r.progress.finish(err)
 	if err != nil {
-		log.Fatal(err.Error())
+		r.fatalf("%s", err)
 	}
 
-	log.Println("Done generating assets.")
+	switch cfg.Command {
+	case "summary":
+		code, err := r.runSummary(cfg)
+		if err != nil {
+			r.fatalf("%s", err)
+		}
+		r.exit(code, cfg.FailOnSeverity)
+	case "report":
+		out, err := createOutput(cfg.Out)
+		if err != nil {
+			r.fatalf("%s", err)
+		}
+		err = r.WriteReport(out, cfg.Format, cfg.ReportTemplate)
+		out.Close()
+		if err != nil {
+			r.fatalf("%s", err)
+		}
+		r.exit(ExitNoChanges, cfg.FailOnSeverity)
+	case "modules":
+		out, err := createOutput(cfg.Out)
+		if err != nil {
+			r.fatalf("%s", err)
+		}
+		err = r.Modules.Write(out, cfg.Format)
+		out.Close()
+		if err != nil {
+			r.fatalf("%s", err)
+		}
+		r.exit(ExitNoChanges, cfg.FailOnSeverity)
+	case "config-diff":
+		// JSON export, otherwise the merged graph is served like a plan
+		if cfg.Format != "" || cfg.Out != "" {
+			if err := r.writeConfigDiff(cfg.Out, cfg.Format); err != nil {
+				r.fatalf("%s", err)
+			}
+			r.exit(0, "")
+		}
+	}
 
 	// Save to file (debug)
 	// saveJSONToFile(name, "plan", "output", r.Plan)
@@ -83,26 +231,159 @@ func runApp(r rover, cfg config.Config) {
 	// Embed frontend
 	fe, err := fs.Sub(frontend, "ui/dist")
 	if err != nil {
-		log.Fatalln(err)
+		r.fatalf("%s", err)
 	}
 
 	if cfg.Standalone {
 		err = r.generateZip(fe, fmt.Sprintf("%s.zip", cfg.ZipFileName))
 		if err != nil {
-			log.Fatalln(err)
+			r.fatalf("%s", err)
 		}
 
 		log.Printf("Generated zip file: %s.zip\n", cfg.ZipFileName)
-		return
+		r.exit(ExitNoChanges, cfg.FailOnSeverity)
+	}
+
+	if r.failsOnFindings(cfg.FailOnSeverity) {
+		log.Printf("Plan has findings with severity %s or higher, see /api/findings", cfg.FailOnSeverity)
 	}
 
-	err = r.startServer(cfg.IPPort)
+	err = r.startServer(ctx, cfg.IPPort)
 	if err != nil {
-		// http.Serve() returns error on shutdown
 		if cfg.GenImage {
-			log.Println("Server shut down.")
+			r.fatalf("Unable to generate image: %s\n", err.Error())
+		}
+		r.fatalf("Could not start server: %s\n", err.Error())
+	}
+	r.cleanup()
+}
+
+// imageOptions converts the -image* flags, the -graphFilter query is passed on to the UI
+func imageOptions(cfg config.Config) ImageOptions {
+	query := graphQuery(cfg)
+
+	return ImageOptions{
+		Path:      cfg.ImagePath,
+		Format:    cfg.ImageFormat,
+		Width:     cfg.ImageWidth,
+		Height:    cfg.ImageHeight,
+		Scale:     cfg.ImageScale,
+		Timeout:   cfg.ImageTimeout,
+		Query:     query,
+		PerModule: cfg.ImagePerModule,
+	}
+}
+
+// graphQuery converts the -graphFilter flags to the query of /api/graph
+func graphQuery(cfg config.Config) url.Values {
+	query := url.Values{}
+	for _, filter := range cfg.GraphFilters {
+		key, value, _ := strings.Cut(filter, "=")
+		query.Add(key, value)
+	}
+	return query
+}
+
+// serves reports whether Rover starts the server after generating the assets
+func (r *rover) serves(cfg config.Config) bool {
+	if cfg.Standalone {
+		return false
+	}
+	switch cfg.Command {
+	case "":
+		return true
+	case "config-diff":
+		return cfg.Format == "" && cfg.Out == ""
+	}
+	return false
+}
+
+// generate creates the assets for the command and writes the findings
+func (r *rover) generate(ctx context.Context, cfg config.Config) error {
+	var err error
+	if cfg.Command == "config-diff" {
+		err = r.generateConfigDiffAssets(cfg.Args)
+	} else if len(r.Roots) > 0 || r.TerragruntDir != "" {
+		if cfg.Command != "" {
+			return fmt.Errorf("%s does not support -root and -terragrunt", cfg.Command)
+		}
+		if r.TerragruntDir != "" {
+			err = r.generateTerragruntAssets(ctx)
 		} else {
-			log.Fatalf("Could not start server: %s\n", err.Error())
+			err = r.generateRootsAssets(ctx)
 		}
+	} else {
+		err = r.generateAssets(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Println("Done generating assets.")
+
+	// /api/source renders editor URLs of the combined roots and config-diff refs too
+	r.loadEditorRepo()
+
+	return r.writeFindings(cfg.SarifOut, cfg.JUnitOut, cfg.Version)
+}
+
+func (r *rover) runSummary(cfg config.Config) (int, error) {
+	out, err := createOutput(cfg.Out)
+	if err != nil {
+		return 1, err
+	}
+	defer out.Close()
+
+	summary := r.GenerateSummary()
+	color := !cfg.NoColor && cfg.Out == ""
+	if err := summary.Write(out, cfg.Format, color); err != nil {
+		return 1, err
+	}
+
+	return summary.ExitCode(cfg.DetailedExitCode, cfg.FailOnDelete), nil
+}
+
+// createOutput opens the output file of a command, or stdout if no path is given
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// cleanup removes the temporary files kept while the assets are served, e.g. the worktrees of config-diff
+func (r *rover) cleanup() {
+	for _, f := range r.cleanups {
+		f()
+	}
+	r.cleanups = nil
+}
+
+// fatalf logs the error and exits with 1 after cleaning up, like log.Fatalf
+func (r *rover) fatalf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+	r.cleanup()
+	os.Exit(1)
+}
+
+// exit terminates with the exit code of the command, see exitCode
+func (r *rover) exit(code int, failOnSeverity string) {
+	r.cleanup()
+	os.Exit(r.exitCode(code, failOnSeverity))
+}
+
+// exitCode returns the exit code of the command, or ExitFindings
+// if findings reach the severity threshold and the command did not fail otherwise
+func (r *rover) exitCode(code int, failOnSeverity string) int {
+	if (code == ExitNoChanges || code == ExitChanges) && r.failsOnFindings(failOnSeverity) {
+		log.Printf("Failing: findings with severity %s or higher", failOnSeverity)
+		return ExitFindings
 	}
+	return code
 }
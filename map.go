@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/terraform-config-inspect/tfconfig"
 	tfjson "github.com/hashicorp/terraform-json"
@@ -25,6 +26,13 @@ const (
 	DefaultFileName      string       = "unknown file"
 )
 
+var (
+	// Instance keys of an address, e.g. [0] or ["a"]
+	matchBrackets = regexp.MustCompile(`\[[^\[\]]*\]`)
+	// Instance key at the end of an address
+	childIndex = regexp.MustCompile(`\[[^[\]]*\]$`)
+)
+
 const (
 	// ActionNoop denotes a no-op operation.
 	ActionNoop Action = "no-op"
@@ -73,6 +81,10 @@ type Resource struct {
 	// ModuleCall
 	Source  string `json:"source,omitempty"`
 	Version string `json:"version,omitempty"`
+	// Git metadata of the declaring block, see -gitBase
+	ChangedInBranch bool   `json:"changedInBranch,omitempty"`
+	LastCommit      string `json:"lastCommit,omitempty"`
+	LastAuthor      string `json:"lastAuthor,omitempty"`
 }
 
 // ModuleCall is a modified tfconfig.ModuleCall
@@ -83,10 +95,11 @@ type ModuleCall struct {
 	Line    int    `json:"line,omitempty"`
 }
 
+// GenerateModuleMap adds the files and resources of a module to parent.
+// Child modules are generated in their own goroutines, they only write to their own Resource.
 func (r *rover) GenerateModuleMap(parent *Resource, parentModule string) {
-
-	childIndex := regexp.MustCompile(`\[[^[\]]*\]$`)
-	matchBrackets := regexp.MustCompile(`\[[^\[\]]*\]`)
+	var wg sync.WaitGroup
+	defer wg.Wait()
 
 	states := r.RSO.States
 	configs := r.RSO.Configs
@@ -101,7 +114,8 @@ func (r *rover) GenerateModuleMap(parent *Resource, parentModule string) {
 
 	// Add variables and outputs with line numbers and file names if configured
 	if parentConfigured && !states[parentModule].IsParent {
-		for oName, o := range configs[parentConfig].Module.Outputs {
+		for _, oName := range sortedKeys(configs[parentConfig].Module.Outputs) {
+			o := configs[parentConfig].Module.Outputs[oName]
 			fname := filepath.Base(o.Pos.Filename)
 			oid := fmt.Sprintf("%soutput.%s", prefix, oName)
 			out := &Resource{
@@ -115,7 +129,8 @@ func (r *rover) GenerateModuleMap(parent *Resource, parentModule string) {
 			parent.Children[fname].Children[oid] = out
 		}
 
-		for vName, v := range configs[parentConfig].Module.Variables {
+		for _, vName := range sortedKeys(configs[parentConfig].Module.Variables) {
+			v := configs[parentConfig].Module.Variables[vName]
 			fname := filepath.Base(v.Pos.Filename)
 			vid := fmt.Sprintf("%svar.%s", prefix, vName)
 			va := &Resource{
@@ -130,9 +145,25 @@ func (r *rover) GenerateModuleMap(parent *Resource, parentModule string) {
 			parent.Children[fname].Children[vid] = va
 
 		}
+
+		for _, lName := range sortedKeys(configs[parentConfig].Locals) {
+			l := configs[parentConfig].Locals[lName]
+			fname := filepath.Base(l.Filename)
+			lid := fmt.Sprintf("%slocal.%s", prefix, lName)
+			lo := &Resource{
+				Type: ResourceTypeLocal,
+				Name: lName,
+				Line: &l.Line,
+			}
+
+			r.AddFileIfNotExists(parent, parentModule, fname)
+
+			parent.Children[fname].Children[lid] = lo
+		}
 		// Add variables and Outputs if no configuration files
 	} else if configs[parentConfig] != nil && configs[parentConfig].ModuleConfig.Module != nil && !states[parentModule].IsParent {
-		for oName, o := range configs[parentConfig].ModuleConfig.Module.Outputs {
+		for _, oName := range sortedKeys(configs[parentConfig].ModuleConfig.Module.Outputs) {
+			o := configs[parentConfig].ModuleConfig.Module.Outputs[oName]
 			oid := fmt.Sprintf("%soutput.%s", prefix, oName)
 			out := &Resource{
 				Type:      ResourceTypeOutput,
@@ -143,7 +174,7 @@ func (r *rover) GenerateModuleMap(parent *Resource, parentModule string) {
 			parent.Children[oid] = out
 		}
 
-		for vName := range configs[parentConfig].ModuleConfig.Module.Variables {
+		for _, vName := range sortedKeys(configs[parentConfig].ModuleConfig.Module.Variables) {
 			vid := fmt.Sprintf("%svar.%s", prefix, vName)
 			va := &Resource{
 				Type: ResourceTypeVariable,
@@ -155,7 +186,8 @@ func (r *rover) GenerateModuleMap(parent *Resource, parentModule string) {
 		}
 	}
 
-	for id, rs := range states[parentModule].Children {
+	for _, id := range sortedKeys(states[parentModule].Children) {
+		rs := states[parentModule].Children[id]
 
 		configId := matchBrackets.ReplaceAllString(id, "")
 		configured := configs[parentConfig] != nil && configs[parentConfig].Module != nil && configs[configId] != nil // If there is configuration for filenames, lines, etc.
@@ -165,19 +197,15 @@ func (r *rover) GenerateModuleMap(parent *Resource, parentModule string) {
 			Children: map[string]*Resource{},
 		}
 
-		if states[id].Change.Actions != nil {
-
-			re.ChangeAction = Action(string(states[id].Change.Actions[0]))
-			if len(states[id].Change.Actions) > 1 {
-				re.ChangeAction = ActionReplace
-			}
-		}
+		re.ChangeAction = changeAction(states[id].Change.Actions)
 
 		if rs.Type == ResourceTypeResource || rs.Type == ResourceTypeData {
 			re.ResourceType = configs[configId].ResourceConfig.Type
 			re.Name = configs[configId].ResourceConfig.Name
+			re.Provider = providerName(configs[configId].ResourceConfig.ProviderConfigKey)
 
-			for crName, cr := range states[id].Children {
+			for _, crName := range sortedKeys(states[id].Children) {
+				cr := states[id].Children[crName]
 
 				if re.Children == nil {
 					re.Children = make(map[string]*Resource)
@@ -193,13 +221,7 @@ func (r *rover) GenerateModuleMap(parent *Resource, parentModule string) {
 					tcr.Name = strings.TrimPrefix(crName, fmt.Sprintf("%s%s.", prefix, re.ResourceType))
 				}
 
-				if cr.Change.Actions != nil {
-					tcr.ChangeAction = Action(string(cr.Change.Actions[0]))
-
-					if len(cr.Change.Actions) > 1 {
-						tcr.ChangeAction = ActionReplace
-					}
-				}
+				tcr.ChangeAction = changeAction(cr.Change.Actions)
 
 				re.Children[crName] = tcr
 			}
@@ -258,25 +280,19 @@ func (r *rover) GenerateModuleMap(parent *Resource, parentModule string) {
 				parent.Children[id] = re
 			}
 
-			r.GenerateModuleMap(re, id)
+			wg.Add(1)
+			go func(re *Resource, id string) {
+				defer wg.Done()
+				r.GenerateModuleMap(re, id)
+			}(re, id)
 
 		}
 
 		// Add locals
 		if configs[configId] != nil && !(re.Type == ResourceTypeModule && childIndex.MatchString(id)) {
-			expressions := map[string]*tfjson.Expression{}
-
-			if re.Type == ResourceTypeResource {
-				expressions = configs[configId].ResourceConfig.Expressions
-			} else if re.Type == ResourceTypeModule {
-				expressions = configs[configId].ModuleConfig.Expressions
-			} else if re.Type == ResourceTypeOutput {
-				expressions["exp"] = configs[configId].OutputConfig.Expression
-			}
-
-			// Add locals
-			for _, reValues := range expressions {
-				for _, dependsOnR := range reValues.References {
+			// Add locals referenced by resources, modules and outputs
+			if re.Type == ResourceTypeResource || re.Type == ResourceTypeModule || re.Type == ResourceTypeOutput {
+				for _, dependsOnR := range r.RSO.References(configId) {
 					ref := &Resource{}
 					if strings.HasPrefix(dependsOnR, "local.") {
 						// Append local variable
@@ -284,6 +300,11 @@ func (r *rover) GenerateModuleMap(parent *Resource, parentModule string) {
 						ref.Name = strings.TrimPrefix(dependsOnR, "local.")
 						rid := fmt.Sprintf("%s%s", prefix, dependsOnR)
 
+						// Locals parsed from the configuration files were added with their file above
+						if parentConfigured && !states[parentModule].IsParent && configs[parentConfig].Locals[ref.Name] != nil {
+							continue
+						}
+
 						if parentConfigured {
 							r.AddFileIfNotExists(parent, parentModule, DefaultFileName)
 							parent.Children[DefaultFileName].Children[rid] = ref
@@ -300,6 +321,63 @@ func (r *rover) GenerateModuleMap(parent *Resource, parentModule string) {
 	}
 }
 
+// changeAction collapses a list of plan actions into a single Action
+func changeAction(actions tfjson.Actions) Action {
+	if len(actions) == 0 {
+		return ""
+	}
+	if len(actions) > 1 {
+		return ActionReplace
+	}
+	return Action(string(actions[0]))
+}
+
+// MapLocation is the file and line a Map entry is declared in
+type MapLocation struct {
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+}
+
+// Locations indexes every entry of the Map by its address.
+// Resource instances (count/for_each) inherit the location of their resource.
+func (m *Map) Locations() map[string]MapLocation {
+	locations := make(map[string]MapLocation)
+	addLocations(locations, "", 0, m.Root)
+	return locations
+}
+
+func addLocations(locations map[string]MapLocation, fname string, line int, resources map[string]*Resource) {
+	for _, id := range sortedKeys(resources) {
+		re := resources[id]
+		switch re.Type {
+		case ResourceTypeFile:
+			addLocations(locations, id, 0, re.Children)
+		case ResourceTypeModule:
+			loc := MapLocation{File: fname}
+			if re.Line != nil {
+				loc.Line = *re.Line
+			}
+			locations[id] = loc
+			addLocations(locations, "", 0, re.Children)
+		default:
+			loc := MapLocation{File: fname, Line: line}
+			if re.Line != nil {
+				loc.Line = *re.Line
+			}
+			locations[id] = loc
+			addLocations(locations, fname, loc.Line, re.Children)
+		}
+	}
+}
+
+// providerName returns the local name of the provider of a provider config key,
+// e.g. random for module.random_cat:random or aws for aws.east
+func providerName(providerConfigKey string) string {
+	name := providerConfigKey[strings.LastIndex(providerConfigKey, ":")+1:]
+	name, _, _ = strings.Cut(name, ".")
+	return name
+}
+
 func (r *rover) AddFileIfNotExists(module *Resource, parentModule string, fname string) {
 
 	if _, ok := module.Children[fname]; !ok {
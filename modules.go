@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// ModuleSourceType is the kind of a module source address
+type ModuleSourceType string
+
+const (
+	ModuleSourceLocal    ModuleSourceType = "local"
+	ModuleSourceRegistry ModuleSourceType = "registry"
+	ModuleSourceGit      ModuleSourceType = "git"
+	ModuleSourceOther    ModuleSourceType = "other"
+)
+
+// Registry module addresses, e.g. terraform-aws-modules/vpc/aws or app.terraform.io/acme/vpc/aws
+var registrySource = regexp.MustCompile(`^(?:([0-9a-z.-]+\.[a-z]{2,}(?::\d+)?)/)?([0-9A-Za-z_-]+)/([0-9A-Za-z_-]+)/([0-9a-z]+)$`)
+
+// ModuleInventory lists the module calls of the configuration at /api/modules
+type ModuleInventory struct {
+	Manifest string         `json:"manifest,omitempty"`
+	Modules  []*ModuleUsage `json:"modules"`
+}
+
+// ModuleUsage is a module call with its source, versions and location
+type ModuleUsage struct {
+	Address    string           `json:"address"`
+	Source     string           `json:"source"`
+	SourceType ModuleSourceType `json:"source_type"`
+	Registry   *RegistrySource  `json:"registry,omitempty"`
+	Git        *GitSource       `json:"git,omitempty"`
+	// Version constraint of registry modules
+	VersionConstraint string `json:"version_constraint,omitempty"`
+	// Version installed from the registry or ref of git sources
+	ResolvedVersion string `json:"resolved_version,omitempty"`
+	// Directory of the installed module, relative to the working directory
+	Dir string `json:"dir,omitempty"`
+	// Resources and data sources declared in the module itself, nested modules excluded
+	Resources int `json:"resources"`
+	// Number of modules the call is nested in, 1 for module calls of the root module
+	Depth int    `json:"depth"`
+	File  string `json:"file,omitempty"`
+	Line  int    `json:"line,omitempty"`
+}
+
+// RegistrySource is a parsed module registry address
+type RegistrySource struct {
+	Host      string `json:"host"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Provider  string `json:"provider"`
+	Subdir    string `json:"subdir,omitempty"`
+}
+
+// GitSource is a parsed git module source, including the GitHub and Bitbucket shorthands
+type GitSource struct {
+	URL    string `json:"url"`
+	Ref    string `json:"ref,omitempty"`
+	Subdir string `json:"subdir,omitempty"`
+}
+
+// readModuleManifest reads the installed modules of .terraform/modules/modules.json by key
+func readModuleManifest(path string) (map[string]ModuleLocation, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := ModuleLocations{}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, err
+	}
+
+	installed := map[string]ModuleLocation{}
+	for _, loc := range manifest.Locations {
+		installed[loc.Key] = loc
+	}
+	return installed, nil
+}
+
+// GenerateModuleInventory collects the module calls of all modules with their sources
+// and the versions and directories installed by terraform init
+func (r *rover) GenerateModuleInventory() error {
+	log.Println("Generating module inventory...")
+
+	inventory := &ModuleInventory{Modules: []*ModuleUsage{}}
+
+	manifestPath := filepath.Join(r.WorkingDir, ".terraform/modules/modules.json")
+	installed, err := readModuleManifest(manifestPath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Unable to read modules.json: %s", err)
+	}
+	if installed != nil {
+		inventory.Manifest = manifestPath
+	}
+
+	// Resources by the address of their module
+	configs := r.RSO.Configs
+	resources := map[string]int{}
+	for id, c := range configs {
+		if c.ResourceConfig == nil || c.ResourceConfig.Address == "" {
+			continue
+		}
+		resources[strings.TrimSuffix(strings.TrimSuffix(id, c.ResourceConfig.Address), ".")]++
+	}
+
+	for _, address := range sortedKeys(configs) {
+		call := configs[address].ModuleConfig
+		if address == "" || call == nil {
+			continue
+		}
+
+		parent, name := "", strings.TrimPrefix(address, "module.")
+		if i := strings.LastIndex(address, ".module."); i >= 0 {
+			parent, name = address[:i], address[i+len(".module."):]
+		}
+
+		m := &ModuleUsage{
+			Address:           address,
+			Source:            call.Source,
+			VersionConstraint: call.VersionConstraint,
+			Resources:         resources[address],
+			Depth:             strings.Count(address, "module."),
+		}
+
+		// The plan only contains the resolved source, the configuration on disk has the position of the call
+		if configs[parent] != nil && configs[parent].Module != nil {
+			if mc := configs[parent].Module.ModuleCalls[name]; mc != nil {
+				if m.Source == "" {
+					m.Source = mc.Source
+				}
+				if m.VersionConstraint == "" {
+					m.VersionConstraint = mc.Version
+				}
+				m.File = mc.Pos.Filename
+				if rel, err := filepath.Rel(r.WorkingDir, mc.Pos.Filename); err == nil {
+					m.File = rel
+				}
+				m.Line = mc.Pos.Line
+			}
+		}
+
+		m.SourceType, m.Registry, m.Git = r.parseModuleSource(m.Source)
+		if m.Git != nil {
+			m.ResolvedVersion = m.Git.Ref
+		}
+
+		// modules.json keys are the module names without the module. prefix, e.g. network.subnets
+		if loc, ok := installed[strings.ReplaceAll(strings.TrimPrefix(address, "module."), ".module.", ".")]; ok {
+			m.Dir = loc.Dir
+			if loc.Version != "" {
+				m.ResolvedVersion = loc.Version
+			}
+		}
+
+		inventory.Modules = append(inventory.Modules, m)
+	}
+
+	r.Modules = inventory
+	return nil
+}
+
+// parseModuleSource determines the type of a module source and parses registry and git sources
+func (r *rover) parseModuleSource(source string) (ModuleSourceType, *RegistrySource, *GitSource) {
+	switch {
+	case source == "":
+		return ModuleSourceOther, nil, nil
+	case strings.HasPrefix(source, "./"), strings.HasPrefix(source, "../"):
+		return ModuleSourceLocal, nil, nil
+	case strings.HasPrefix(source, "git::"), strings.HasPrefix(source, "git@"),
+		strings.HasPrefix(source, "github.com/"), strings.HasPrefix(source, "bitbucket.org/"):
+		return ModuleSourceGit, nil, parseGitSource(strings.TrimPrefix(source, "git::"))
+	}
+
+	address, subdir := splitSubdir(source)
+	if match := registrySource.FindStringSubmatch(address); match != nil {
+		host := match[1]
+		if host == "" {
+			host = "registry.terraform.io"
+			if r.Meta.Flavor == FlavorOpenTofu {
+				host = "registry.opentofu.org"
+			}
+		}
+		return ModuleSourceRegistry, &RegistrySource{
+			Host:      host,
+			Namespace: match[2],
+			Name:      match[3],
+			Provider:  match[4],
+			Subdir:    subdir,
+		}, nil
+	}
+
+	return ModuleSourceOther, nil, nil
+}
+
+// parseGitSource splits a git source into repository, ref and subdirectory,
+// e.g. https://example.com/vpc.git//modules/subnets?ref=v1.2.0
+func parseGitSource(source string) *GitSource {
+	g := &GitSource{}
+	source, query, _ := strings.Cut(source, "?")
+	g.URL, g.Subdir = splitSubdir(source)
+
+	if values, err := url.ParseQuery(query); err == nil {
+		g.Ref = values.Get("ref")
+		values.Del("ref")
+		// Other parameters like depth belong to the repository URL
+		if len(values) > 0 {
+			g.URL = fmt.Sprintf("%s?%s", g.URL, values.Encode())
+		}
+	}
+	return g
+}
+
+// splitSubdir splits the subdirectory after // from a source, the // of a URL scheme excluded
+func splitSubdir(source string) (string, string) {
+	offset := 0
+	if i := strings.Index(source, "://"); i >= 0 {
+		offset = i + len("://")
+	}
+	if i := strings.Index(source[offset:], "//"); i >= 0 {
+		return source[:offset+i], source[offset+i+len("//"):]
+	}
+	return source, ""
+}
+
+// Write writes the inventory as table (text) or json
+func (inv *ModuleInventory) Write(w io.Writer, format string) error {
+	switch format {
+	case "", "text":
+		inv.writeText(w)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(inv)
+	default:
+		return fmt.Errorf("unknown modules format %q (available: text, json)", format)
+	}
+	return nil
+}
+
+func (inv *ModuleInventory) writeText(w io.Writer) {
+	if len(inv.Modules) == 0 {
+		fmt.Fprintln(w, "No module calls.")
+		return
+	}
+
+	dash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tTYPE\tSOURCE\tCONSTRAINT\tRESOLVED\tRESOURCES\tLOCATION")
+	for _, m := range inv.Modules {
+		location := dash(m.File)
+		if m.Line > 0 {
+			location = fmt.Sprintf("%s:%d", m.File, m.Line)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", m.Address, m.SourceType, dash(m.Source),
+			dash(m.VersionConstraint), dash(m.ResolvedVersion), m.Resources, location)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseModuleSource(t *testing.T) {
+	tests := []struct {
+		source   string
+		flavor   Flavor
+		want     ModuleSourceType
+		registry *RegistrySource
+		git      *GitSource
+	}{
+		{source: "./modules/vpc", want: ModuleSourceLocal},
+		{source: "../shared", want: ModuleSourceLocal},
+		{
+			source:   "terraform-aws-modules/vpc/aws",
+			want:     ModuleSourceRegistry,
+			registry: &RegistrySource{Host: "registry.terraform.io", Namespace: "terraform-aws-modules", Name: "vpc", Provider: "aws"},
+		},
+		{
+			source:   "terraform-aws-modules/vpc/aws",
+			flavor:   FlavorOpenTofu,
+			want:     ModuleSourceRegistry,
+			registry: &RegistrySource{Host: "registry.opentofu.org", Namespace: "terraform-aws-modules", Name: "vpc", Provider: "aws"},
+		},
+		{
+			source:   "app.terraform.io/acme/vpc/aws",
+			want:     ModuleSourceRegistry,
+			registry: &RegistrySource{Host: "app.terraform.io", Namespace: "acme", Name: "vpc", Provider: "aws"},
+		},
+		{
+			source:   "registry.example.com:8443/acme/vpc/aws//modules/subnets",
+			want:     ModuleSourceRegistry,
+			registry: &RegistrySource{Host: "registry.example.com:8443", Namespace: "acme", Name: "vpc", Provider: "aws", Subdir: "modules/subnets"},
+		},
+		{
+			source: "git::https://example.com/vpc.git//modules/subnets?ref=v1.2.0",
+			want:   ModuleSourceGit,
+			git:    &GitSource{URL: "https://example.com/vpc.git", Ref: "v1.2.0", Subdir: "modules/subnets"},
+		},
+		{
+			source: "git::https://example.com/vpc.git?depth=1&ref=main",
+			want:   ModuleSourceGit,
+			git:    &GitSource{URL: "https://example.com/vpc.git?depth=1", Ref: "main"},
+		},
+		{
+			source: "git::ssh://git@example.com/vpc.git",
+			want:   ModuleSourceGit,
+			git:    &GitSource{URL: "ssh://git@example.com/vpc.git"},
+		},
+		{
+			source: "git@github.com:acme/vpc.git?ref=v1",
+			want:   ModuleSourceGit,
+			git:    &GitSource{URL: "git@github.com:acme/vpc.git", Ref: "v1"},
+		},
+		{
+			source: "github.com/acme/vpc//modules/subnets?ref=v2",
+			want:   ModuleSourceGit,
+			git:    &GitSource{URL: "github.com/acme/vpc", Ref: "v2", Subdir: "modules/subnets"},
+		},
+		{source: "", want: ModuleSourceOther},
+		{source: "https://example.com/vpc.zip", want: ModuleSourceOther},
+		{source: "s3::https://s3.amazonaws.com/bucket/vpc.zip", want: ModuleSourceOther},
+		{source: "hashicorp/consul/aws/extra", want: ModuleSourceOther},
+	}
+
+	for _, tt := range tests {
+		r := &rover{Meta: Meta{Flavor: tt.flavor}}
+		got, registry, git := r.parseModuleSource(tt.source)
+		if got != tt.want {
+			t.Errorf("parseModuleSource(%q) = %s, want %s", tt.source, got, tt.want)
+		}
+		if !reflect.DeepEqual(registry, tt.registry) {
+			t.Errorf("parseModuleSource(%q) registry = %+v, want %+v", tt.source, registry, tt.registry)
+		}
+		if !reflect.DeepEqual(git, tt.git) {
+			t.Errorf("parseModuleSource(%q) git = %+v, want %+v", tt.source, git, tt.git)
+		}
+	}
+}
+
+func TestSplitSubdir(t *testing.T) {
+	tests := []struct {
+		source string
+		base   string
+		subdir string
+	}{
+		{"hashicorp/consul/aws", "hashicorp/consul/aws", ""},
+		{"hashicorp/consul/aws//modules/consul-cluster", "hashicorp/consul/aws", "modules/consul-cluster"},
+		{"https://example.com/vpc.git", "https://example.com/vpc.git", ""},
+		{"https://example.com/vpc.git//modules/subnets", "https://example.com/vpc.git", "modules/subnets"},
+		{"ssh://git@example.com/vpc.git//a/b", "ssh://git@example.com/vpc.git", "a/b"},
+	}
+
+	for _, tt := range tests {
+		base, subdir := splitSubdir(tt.source)
+		if base != tt.base || subdir != tt.subdir {
+			t.Errorf("splitSubdir(%q) = %q, %q, want %q, %q", tt.source, base, subdir, tt.base, tt.subdir)
+		}
+	}
+}
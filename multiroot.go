@@ -0,0 +1,537 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// rootSeparator separates the root name from the address in namespaced IDs, e.g. network::aws_vpc.main
+const rootSeparator = "::"
+
+const remoteStateClass = "remote-state"
+
+// rootModule is one of several root modules combined with -root
+type rootModule struct {
+	Name string
+	Dir  string
+	// Backend type and configuration, e.g. s3 with bucket and key
+	Backend       string
+	BackendConfig map[string]string
+	rover         *rover
+}
+
+// namespace prefixes an ID with the name of its root. The root module itself is the root name.
+func namespace(root string, id string) string {
+	if id == "" {
+		return root
+	}
+	return root + rootSeparator + id
+}
+
+// splitNamespace returns the root name and the address of a namespaced ID, the root is "" for other IDs
+func splitNamespace(id string) (string, string) {
+	if root, address, ok := strings.Cut(id, rootSeparator); ok {
+		return root, address
+	}
+	return "", id
+}
+
+// loadRoots resolves the -root values, which are working directories or plan JSON files.
+// Roots are named by their path relative to the current directory.
+func (r *rover) loadRoots() ([]*rootModule, error) {
+	cwd, _ := os.Getwd()
+
+	roots := []*rootModule{}
+	names := map[string]bool{}
+
+	for _, value := range r.Roots {
+		if value == "" {
+			continue
+		}
+
+		info, err := os.Stat(value)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read root %s: %s", value, err)
+		}
+
+		root := &rootModule{Dir: value}
+		planJSONPath := ""
+		if !info.IsDir() {
+			planJSONPath, _ = filepath.Abs(value)
+			root.Dir = filepath.Dir(value)
+		}
+
+		root.Name = filepath.Base(root.Dir)
+		if abs, err := filepath.Abs(root.Dir); err == nil {
+			root.Dir = abs
+			if rel, err := filepath.Rel(cwd, abs); err == nil && !strings.HasPrefix(rel, "..") && rel != "." {
+				root.Name = filepath.ToSlash(rel)
+			}
+		}
+
+		if names[root.Name] {
+			return nil, fmt.Errorf("Root %s is given more than once", root.Name)
+		}
+		names[root.Name] = true
+
+		root.rover = r.rootRover(root.Dir, planJSONPath)
+		root.Backend, root.BackendConfig = loadBackend(root.Dir)
+
+		roots = append(roots, root)
+	}
+
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].Name < roots[j].Name
+	})
+
+	return roots, nil
+}
+
+// rootRover copies the options of r for a single root
+func (r *rover) rootRover(dir string, planJSONPath string) *rover {
+	sub := *r
+	sub.Roots = nil
+	sub.TerragruntDir = ""
+	sub.WorkingDir = dir
+	sub.PlanPath = ""
+	sub.PlanJSONPath = planJSONPath
+	sub.Findings = nil
+	sub.Lint = nil
+	sub.Rules = nil
+	sub.Git = nil
+	sub.gitRepo = nil
+	return &sub
+}
+
+// generateRootsAssets generates the assets of the -root modules and combines them
+func (r *rover) generateRootsAssets(ctx context.Context) error {
+	roots, err := r.loadRoots()
+	if err != nil {
+		return err
+	}
+
+	return r.combineRoots(ctx, roots)
+}
+
+// combineRoots generates the assets of every root and combines them.
+// All IDs of the Map, RSO, Graph and findings are namespaced by the root name.
+func (r *rover) combineRoots(ctx context.Context, roots []*rootModule) error {
+	r.Plans = map[string]*tfjson.Plan{}
+	r.Plan = &tfjson.Plan{}
+	r.RSO = &ResourcesOverview{
+		Locations: map[string]string{},
+		Configs:   map[string]*ConfigOverview{},
+		States:    map[string]*StateOverview{},
+	}
+	r.Map = &Map{
+		Path: "Rover Visualization",
+		Root: map[string]*Resource{},
+	}
+	r.Graph = Graph{Nodes: []Node{}, Edges: []Edge{}}
+	r.Findings = []Finding{}
+	r.Lint = []Finding{}
+	r.Rules = []FindingRule{}
+	if r.GitBase != "" {
+		r.Git = map[string]*GitBlock{}
+	}
+
+	rules := map[string]bool{}
+
+	for _, root := range roots {
+		log.Printf("Generating assets of root %s...", root.Name)
+
+		sub := root.rover
+		sub.rootName = root.Name
+		if err := sub.generateAssets(ctx); err != nil {
+			return fmt.Errorf("%s: %s", root.Name, err)
+		}
+
+		r.Plans[root.Name] = sub.Plan
+		r.addRootRSO(root.Name, sub.RSO)
+		r.Map.Root[root.Name] = &Resource{
+			Type:     ResourceTypeModule,
+			Name:     root.Name,
+			Source:   root.Dir,
+			Children: namespaceResources(root.Name, sub.Map.Root),
+		}
+		r.addRootGraph(root.Name, sub.Map.Path, sub.Graph)
+
+		for _, f := range sub.Findings {
+			f.Address = namespace(root.Name, f.Address)
+			r.Findings = append(r.Findings, f)
+		}
+		for _, f := range sub.Lint {
+			f.Address = namespace(root.Name, f.Address)
+			r.Lint = append(r.Lint, f)
+		}
+		for _, rule := range sub.Rules {
+			if !rules[rule.ID] {
+				rules[rule.ID] = true
+				r.Rules = append(r.Rules, rule)
+			}
+		}
+		for address, gb := range sub.Git {
+			r.Git[namespace(root.Name, address)] = gb
+		}
+	}
+
+	r.linkRemoteStates(roots)
+
+	return nil
+}
+
+func (r *rover) addRootRSO(name string, rso *ResourcesOverview) {
+	for key, location := range rso.Locations {
+		r.RSO.Locations[namespace(name, key)] = location
+	}
+	for id, config := range rso.Configs {
+		r.RSO.Configs[namespace(name, id)] = config
+	}
+	for id, state := range rso.States {
+		children := make(map[string]*StateOverview, len(state.Children))
+		for childID, child := range state.Children {
+			children[namespace(name, childID)] = child
+		}
+		if state.Children != nil {
+			state.Children = children
+		}
+		r.RSO.States[namespace(name, id)] = state
+	}
+}
+
+// namespaceResources copies a Map tree with namespaced IDs. Files keep their names.
+func namespaceResources(name string, resources map[string]*Resource) map[string]*Resource {
+	if resources == nil {
+		return nil
+	}
+
+	namespaced := make(map[string]*Resource, len(resources))
+	for id, re := range resources {
+		if re.Type != ResourceTypeFile {
+			id = namespace(name, id)
+		}
+		re.Children = namespaceResources(name, re.Children)
+		namespaced[id] = re
+	}
+	return namespaced
+}
+
+// addRootGraph adds the graph of a root. Its base node becomes the compound node of the root.
+func (r *rover) addRootGraph(name string, mapPath string, g Graph) {
+	basePath := strings.ReplaceAll(mapPath, "./", "")
+
+	for _, n := range g.Nodes {
+		if n.Data.ID == basePath && n.Data.Type == "basename" {
+			n.Data.ID = name
+			n.Data.Label = name
+			if n.Data.PlanMode != "" {
+				n.Data.Label = fmt.Sprintf("%s [%s]", name, n.Data.PlanMode)
+			}
+			n.Classes = strings.TrimSpace(fmt.Sprintf("%s root", n.Classes))
+		} else {
+			n.Data.ID = namespace(name, n.Data.ID)
+			if n.Data.Parent == basePath {
+				n.Data.Parent = name
+			} else if n.Data.Parent != "" {
+				n.Data.Parent = namespace(name, n.Data.Parent)
+			}
+		}
+
+		for i := range n.Data.Findings {
+			n.Data.Findings[i].Address = namespace(name, n.Data.Findings[i].Address)
+		}
+
+		r.Graph.Nodes = append(r.Graph.Nodes, n)
+	}
+
+	for _, e := range g.Edges {
+		e.Data.Source = namespace(name, e.Data.Source)
+		e.Data.Target = namespace(name, e.Data.Target)
+		e.Data.ID = fmt.Sprintf("%s->%s", e.Data.Source, e.Data.Target)
+		r.Graph.Edges = append(r.Graph.Edges, e)
+	}
+}
+
+// linkRemoteStates adds edges from terraform_remote_state data sources to the
+// outputs they read from the producing root, or to the root if no output is referenced
+func (r *rover) linkRemoteStates(roots []*rootModule) {
+	nodes := map[string]bool{}
+	for _, n := range r.Graph.Nodes {
+		nodes[n.Data.ID] = true
+	}
+
+	for _, consumer := range roots {
+		configs := consumer.rover.RSO.Configs
+
+		for _, address := range sortedKeys(configs) {
+			rc := configs[address].ResourceConfig
+			if rc == nil || rc.Type != "terraform_remote_state" || rc.Mode != tfjson.DataResourceMode {
+				continue
+			}
+
+			backend, config := consumer.rover.remoteStateConfig(address)
+			producer := matchRemoteState(consumer, roots, backend, config)
+			if producer == nil {
+				log.Printf("No root found for %s in %s", address, consumer.Name)
+				continue
+			}
+
+			source := namespace(consumer.Name, address)
+			if !nodes[source] {
+				continue
+			}
+
+			targets := []string{}
+			for _, output := range remoteStateOutputs(consumer.rover, address) {
+				target := namespace(producer.Name, "output."+output)
+				if nodes[target] {
+					targets = append(targets, target)
+				}
+			}
+			if len(targets) == 0 {
+				targets = append(targets, producer.Name)
+			}
+
+			for _, target := range targets {
+				r.Graph.Edges = append(r.Graph.Edges, Edge{
+					Data: EdgeData{
+						ID:       fmt.Sprintf("%s->%s", source, target),
+						Source:   source,
+						Target:   target,
+						Gradient: fmt.Sprintf("%s %s", DATA_COLOR, OUTPUT_COLOR),
+					},
+					Classes: fmt.Sprintf("edge %s", remoteStateClass),
+				})
+			}
+
+			log.Printf("Linked %s to root %s", source, producer.Name)
+		}
+	}
+}
+
+// remoteStateConfig returns the backend and configuration of a terraform_remote_state data source,
+// from the constant expressions of the configuration or else from the values read during the plan
+func (r *rover) remoteStateConfig(address string) (string, map[string]string) {
+	backend := ""
+	config := map[string]string{}
+
+	rc := r.RSO.Configs[address].ResourceConfig
+	if exp, ok := rc.Expressions["backend"]; ok && exp.ExpressionData != nil {
+		if s, ok := exp.ConstantValue.(string); ok {
+			backend = s
+		}
+	}
+	if exp, ok := rc.Expressions["config"]; ok && exp.ExpressionData != nil {
+		flattenConfig(config, "", exp.ConstantValue)
+	}
+
+	if state, ok := r.RSO.States[address]; ok {
+		if attrs, ok := state.plannedValues().(map[string]interface{}); ok {
+			if s, ok := attrs["backend"].(string); ok && backend == "" {
+				backend = s
+			}
+			if len(config) == 0 {
+				flattenConfig(config, "", attrs["config"])
+			}
+		}
+	}
+
+	if backend == "" {
+		backend = "local"
+	}
+
+	return backend, config
+}
+
+// remoteStateOutputs lists the outputs of a remote state that its module references
+func remoteStateOutputs(r *rover, address string) []string {
+	prefix := moduleAddress.FindString(address)
+	module := strings.TrimSuffix(prefix, ".")
+	local := strings.TrimPrefix(address, prefix) + ".outputs."
+
+	refs := newModuleReferences()
+	if config, ok := r.RSO.Configs[module]; ok && config.ModuleConfig != nil && config.ModuleConfig.Module != nil {
+		refs = r.moduleReferences(module, config)
+	} else if ok && config.Module != nil {
+		// Modules without plan configuration only have their parsed locals
+		for _, l := range config.Locals {
+			refs.add(l.References)
+		}
+	}
+
+	outputs := map[string]bool{}
+	for ref := range refs.refs {
+		if strings.HasPrefix(ref, local) {
+			outputs[strings.Split(strings.TrimPrefix(ref, local), ".")[0]] = true
+		}
+	}
+
+	return sortedKeys(outputs)
+}
+
+// matchRemoteState finds the root whose state a remote state data source reads.
+// Local paths are resolved relative to the consumer, other backends are compared by
+// their identifying settings. Without a match, a root name in the state key is used.
+func matchRemoteState(consumer *rootModule, roots []*rootModule, backend string, config map[string]string) *rootModule {
+	if backend == "local" {
+		path := config["path"]
+		if path == "" {
+			return nil
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(consumer.Dir, path)
+		}
+		for _, root := range roots {
+			if root != consumer && filepath.Dir(filepath.Clean(path)) == root.Dir {
+				return root
+			}
+		}
+		return nil
+	}
+
+	identifying := []string{"key", "prefix", "path", "workspaces.name", "workspaces.prefix", "name"}
+	scoping := []string{"bucket", "container_name", "storage_account_name", "organization", "hostname", "address"}
+
+	for _, root := range roots {
+		if root == consumer || root.Backend != backend {
+			continue
+		}
+		matched := false
+		conflict := false
+		for _, k := range identifying {
+			if v, ok := config[k]; ok && root.BackendConfig[k] != "" {
+				if v == root.BackendConfig[k] {
+					matched = true
+				} else {
+					conflict = true
+				}
+			}
+		}
+		for _, k := range scoping {
+			if v, ok := config[k]; ok && root.BackendConfig[k] != "" && v != root.BackendConfig[k] {
+				conflict = true
+			}
+		}
+		if matched && !conflict {
+			return root
+		}
+	}
+
+	// Naming convention, e.g. key = "network/terraform.tfstate" for the root network
+	for _, k := range identifying {
+		segments := strings.FieldsFunc(config[k], func(c rune) bool {
+			return c == '/' || c == ':' || c == '.'
+		})
+		for _, segment := range segments {
+			for _, root := range roots {
+				if root != consumer && (segment == root.Name || segment == filepath.Base(root.Dir)) {
+					return root
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// flattenConfig flattens nested maps into dotted keys, e.g. workspaces.name
+func flattenConfig(config map[string]string, prefix string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, el := range v {
+			flattenConfig(config, prefix+k+".", el)
+		}
+	case []interface{}:
+		// Blocks like workspaces are lists with a single object
+		if len(v) == 1 {
+			flattenConfig(config, prefix, v[0])
+		}
+	case nil:
+	default:
+		config[strings.TrimSuffix(prefix, ".")] = fmt.Sprint(v)
+	}
+}
+
+// loadBackend reads the backend of a root from .terraform/terraform.tfstate, which
+// includes -backend-config values, or else from the terraform block of the configuration
+func loadBackend(dir string) (string, map[string]string) {
+	config := map[string]string{}
+
+	if b, err := os.ReadFile(filepath.Join(dir, ".terraform", "terraform.tfstate")); err == nil {
+		var state struct {
+			Backend *struct {
+				Type   string                 `json:"type"`
+				Config map[string]interface{} `json:"config"`
+			} `json:"backend"`
+		}
+		if json.Unmarshal(b, &state) == nil && state.Backend != nil {
+			flattenConfig(config, "", state.Backend.Config)
+			return state.Backend.Type, config
+		}
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return "local", config
+	}
+
+	parser := hclparse.NewParser()
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".tf") {
+			continue
+		}
+		file, diags := parser.ParseHCLFile(filepath.Join(dir, f.Name()))
+		if diags.HasErrors() {
+			continue
+		}
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+
+		for _, block := range body.Blocks {
+			if block.Type != "terraform" {
+				continue
+			}
+			for _, nested := range block.Body.Blocks {
+				switch {
+				case nested.Type == "backend" && len(nested.Labels) == 1:
+					addBodyConfig(config, "", nested.Body)
+					return nested.Labels[0], config
+				case nested.Type == "cloud":
+					addBodyConfig(config, "", nested.Body)
+					return "remote", config
+				}
+			}
+		}
+	}
+
+	// The default local backend stores terraform.tfstate in the root
+	config["path"] = filepath.Join(dir, "terraform.tfstate")
+	return "local", config
+}
+
+// addBodyConfig adds the constant string attributes of a body and its nested blocks
+func addBodyConfig(config map[string]string, prefix string, body *hclsyntax.Body) {
+	for name, attr := range body.Attributes {
+		v, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || !v.IsKnown() || v.IsNull() || !v.Type().Equals(cty.String) {
+			continue
+		}
+		config[prefix+name] = v.AsString()
+	}
+	for _, nested := range body.Blocks {
+		addBodyConfig(config, prefix+nested.Type+".", nested.Body)
+	}
+}
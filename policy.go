@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyFile is a set of rules, read from YAML or HCL
+type PolicyFile struct {
+	Rules []*PolicyRule `yaml:"rules" hcl:"rule,block"`
+}
+
+// PolicyRule matches resource changes. All given conditions must match.
+type PolicyRule struct {
+	ID          string   `yaml:"id" hcl:"id,label"`
+	Description string   `yaml:"description" hcl:"description,optional"`
+	Severity    Severity `yaml:"severity" hcl:"severity,optional"`
+	Message     string   `yaml:"message" hcl:"message,optional"`
+	// Glob on the resource address, e.g. module.network.*
+	Address string `yaml:"address" hcl:"address,optional"`
+	// Globs on the resource type, the rule matches if any matches
+	Types []string `yaml:"type" hcl:"type,optional"`
+	// Globs on the resource type, the rule matches if none matches (allowlist)
+	NotTypes []string `yaml:"not_type" hcl:"not_type,optional"`
+	// Change actions, e.g. delete or replace
+	Actions    []string          `yaml:"action" hcl:"action,optional"`
+	Attributes []*AttributeMatch `yaml:"attribute" hcl:"attribute,block"`
+}
+
+// AttributeMatch checks a value in Change.After (Change.Before for deletes).
+// Path elements are separated by dots, * matches any list index or map key.
+type AttributeMatch struct {
+	Path     string  `yaml:"path" hcl:"path"`
+	Equals   *string `yaml:"equals" hcl:"equals,optional"`
+	Contains *string `yaml:"contains" hcl:"contains,optional"`
+	Absent   *bool   `yaml:"absent" hcl:"absent,optional"`
+}
+
+// LoadPolicies reads the rules from the given YAML or HCL files
+func LoadPolicies(paths []string) ([]*PolicyRule, error) {
+	rules := []*PolicyRule{}
+
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+
+		src, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read policy file (%s): %s", p, err)
+		}
+
+		pf := &PolicyFile{}
+		if strings.HasSuffix(p, ".hcl") {
+			file, diags := hclsyntax.ParseConfig(src, p, hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			if diags := gohcl.DecodeBody(file.Body, nil, pf); diags.HasErrors() {
+				return nil, diags
+			}
+		} else if err := yaml.Unmarshal(src, pf); err != nil {
+			return nil, fmt.Errorf("%s: %s", p, err)
+		}
+
+		for i, rule := range pf.Rules {
+			if err := rule.validate(); err != nil {
+				return nil, fmt.Errorf("%s: rule %d (%s): %s", p, i+1, rule.ID, err)
+			}
+		}
+
+		rules = append(rules, pf.Rules...)
+	}
+
+	return rules, nil
+}
+
+func (rule *PolicyRule) validate() error {
+	if rule.ID == "" {
+		return fmt.Errorf("key \"id\" is required")
+	}
+
+	if rule.Severity == "" {
+		rule.Severity = SeverityMedium
+	}
+	if severityLevel(rule.Severity) < 0 {
+		return fmt.Errorf("key \"severity\" must be one of info, low, medium, high, critical")
+	}
+
+	for _, a := range rule.Actions {
+		switch Action(a) {
+		case ActionNoop, ActionCreate, ActionRead, ActionUpdate, ActionDelete, ActionReplace:
+		default:
+			return fmt.Errorf("key \"action\" has unknown action %q", a)
+		}
+	}
+
+	for _, pattern := range append(append([]string{}, rule.Types...), rule.NotTypes...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob %q", pattern)
+		}
+	}
+
+	for _, am := range rule.Attributes {
+		if am.Path == "" {
+			return fmt.Errorf("key \"attribute.path\" is required")
+		}
+		if am.Equals == nil && am.Contains == nil && am.Absent == nil {
+			return fmt.Errorf("attribute %q needs one of equals, contains or absent", am.Path)
+		}
+	}
+
+	return nil
+}
+
+// EvaluatePolicies evaluates the rules against the resource changes in the overview
+// and attaches the findings to the graph nodes
+func (r *rover) EvaluatePolicies(rules []*PolicyRule) {
+	if r.Findings == nil {
+		r.Findings = []Finding{}
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	log.Println("Evaluating policies...")
+
+	count := len(r.Findings)
+	for _, rule := range rules {
+		description := rule.Description
+		if description == "" {
+			description = rule.Message
+		}
+		r.Rules = append(r.Rules, FindingRule{
+			ID:          rule.ID,
+			Description: description,
+			Severity:    rule.Severity,
+			Category:    "policy",
+		})
+	}
+
+	addresses := make([]string, 0, len(r.RSO.States))
+	for id := range r.RSO.States {
+		addresses = append(addresses, id)
+	}
+	sort.Strings(addresses)
+
+	for _, address := range addresses {
+		state := r.RSO.States[address]
+		if state.Type != ResourceTypeResource && state.Type != ResourceTypeData {
+			continue
+		}
+		if state.Change.Actions == nil {
+			continue
+		}
+
+		resourceType := ""
+		if rc := r.RSO.Configs[matchBrackets.ReplaceAllString(address, "")]; rc != nil && rc.ResourceConfig != nil {
+			resourceType = rc.ResourceConfig.Type
+		}
+
+		for _, rule := range rules {
+			// With -root and -terragrunt, rules can also select the root, e.g. network::aws_vpc.*
+			matched := rule.matches(address, resourceType, state) ||
+				r.rootName != "" && rule.matches(namespace(r.rootName, address), resourceType, state)
+			if !matched {
+				continue
+			}
+
+			message := rule.Message
+			if message == "" {
+				message = rule.Description
+			}
+			if message == "" {
+				message = fmt.Sprintf("%s matches rule %s", address, rule.ID)
+			}
+
+			r.Findings = append(r.Findings, Finding{
+				RuleID:   rule.ID,
+				Severity: rule.Severity,
+				Message:  message,
+				Address:  address,
+			})
+		}
+	}
+
+	log.Printf("Found %d policy findings.", len(r.Findings)-count)
+
+	r.locateFindings(r.Findings[count:])
+	r.annotateFindings()
+}
+
+func (rule *PolicyRule) matches(address string, resourceType string, state *StateOverview) bool {
+	if rule.Address != "" {
+		if !matchAddress(rule.Address, address) {
+			return false
+		}
+	}
+
+	if len(rule.Types) > 0 && !matchAny(rule.Types, resourceType) {
+		return false
+	}
+
+	if len(rule.NotTypes) > 0 && matchAny(rule.NotTypes, resourceType) {
+		return false
+	}
+
+	if len(rule.Actions) > 0 {
+		action := string(changeAction(state.Change.Actions))
+		found := false
+		for _, a := range rule.Actions {
+			if a == action {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	values := state.plannedValues()
+	for _, am := range rule.Attributes {
+		if !am.matches(values) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (am *AttributeMatch) matches(values interface{}) bool {
+	found := lookupAttribute(values, strings.Split(am.Path, "."))
+
+	if am.Absent != nil {
+		absent := true
+		for _, v := range found {
+			if !isEmptyValue(v) {
+				absent = false
+				break
+			}
+		}
+		if absent != *am.Absent {
+			return false
+		}
+	}
+
+	if am.Equals != nil {
+		ok := false
+		for _, v := range found {
+			if v != nil && fmt.Sprint(v) == *am.Equals {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	if am.Contains != nil {
+		ok := false
+		for _, v := range found {
+			switch value := v.(type) {
+			case string:
+				ok = strings.Contains(value, *am.Contains)
+			case []interface{}:
+				for _, el := range value {
+					if fmt.Sprint(el) == *am.Contains {
+						ok = true
+						break
+					}
+				}
+			}
+			if ok {
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// lookupAttribute returns all values at the path, expanding * for lists and maps
+func lookupAttribute(value interface{}, steps []string) []interface{} {
+	if len(steps) == 0 {
+		return []interface{}{value}
+	}
+
+	step, rest := steps[0], steps[1:]
+	found := []interface{}{}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if step == "*" {
+			for _, key := range sortedKeys(v) {
+				found = append(found, lookupAttribute(v[key], rest)...)
+			}
+		} else if el, ok := v[step]; ok {
+			found = append(found, lookupAttribute(el, rest)...)
+		}
+	case []interface{}:
+		for i, el := range v {
+			if step == "*" || step == fmt.Sprint(i) {
+				found = append(found, lookupAttribute(el, rest)...)
+			}
+		}
+	}
+
+	return found
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case []interface{}:
+		return len(value) == 0
+	case map[string]interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
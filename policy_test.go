@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// generateRandomTest generates the assets of example/random-test from its plan JSON
+func generateRandomTest(t *testing.T) *rover {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	r := &rover{
+		WorkingDir:   "example/random-test",
+		PlanJSONPath: "testdata/random-test/plan.json",
+	}
+	if err := r.getPlan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, generate := range []func() error{r.GenerateResourceOverview, r.GenerateMap, r.GenerateGraph} {
+		if err := generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+const testPolicies = `rules:
+  - id: no-pet-replace
+    severity: high
+    type: [random_pet]
+    action: [replace]
+  - id: no-aws
+    type: ["aws_*"]
+  - id: long-pets
+    severity: low
+    type: [random_pet]
+    attribute:
+      - path: length
+        equals: "3"
+`
+
+func TestEvaluatePolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	if err := os.WriteFile(path, []byte(testPolicies), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadPolicies([]string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := generateRandomTest(t)
+	r.EvaluatePolicies(rules)
+
+	if len(r.Rules) != 3 {
+		t.Errorf("rules = %+v, want all 3 rules, with or without findings", r.Rules)
+	}
+
+	// Only random_pet.cow is replaced, no random_pet has length 3 and there are no aws resources
+	if len(r.Findings) != 1 {
+		t.Fatalf("findings = %+v, want no-pet-replace on random_pet.cow", r.Findings)
+	}
+	f := r.Findings[0]
+	if f.RuleID != "no-pet-replace" || f.Address != "random_pet.cow" || f.Severity != SeverityHigh {
+		t.Errorf("finding = %+v, want no-pet-replace on random_pet.cow with severity high", f)
+	}
+
+	for _, n := range r.Graph.Nodes {
+		want := 0
+		if n.Data.ID == "random_pet.cow" {
+			want = 1
+		}
+		if len(n.Data.Findings) != want {
+			t.Errorf("node %s has findings %+v, want %d", n.Data.ID, n.Data.Findings, want)
+		}
+	}
+}
+
+func TestLoadPoliciesInvalid(t *testing.T) {
+	tests := map[string]string{
+		"rules:\n  - severity: high\n":                              `key "id" is required`,
+		"rules:\n  - id: a\n    severity: urgent\n":                 `key "severity" must be one of`,
+		"rules:\n  - id: a\n    action: [destroy]\n":                `unknown action "destroy"`,
+		"rules:\n  - id: a\n    attribute:\n      - path: length\n": `attribute "length" needs one of`,
+	}
+
+	for src, want := range tests {
+		path := filepath.Join(t.TempDir(), "policies.yaml")
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadPolicies([]string{path})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadPolicies(%q) error = %v, want %q", src, err, want)
+		}
+	}
+}
+
+func TestFailOnSeverity(t *testing.T) {
+	r := &rover{Findings: []Finding{{RuleID: "a", Severity: SeverityMedium}, {RuleID: "b", Severity: SeverityInfo}}}
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	tests := []struct {
+		threshold string
+		code      int
+		want      int
+	}{
+		{threshold: "", code: ExitNoChanges, want: ExitNoChanges},
+		{threshold: "info", code: ExitNoChanges, want: ExitFindings},
+		{threshold: "medium", code: ExitChanges, want: ExitFindings},
+		{threshold: "high", code: ExitChanges, want: ExitChanges},
+		{threshold: "critical", code: ExitNoChanges, want: ExitNoChanges},
+		// Other failures take precedence over findings
+		{threshold: "info", code: ExitProtectedDelete, want: ExitProtectedDelete},
+		{threshold: "info", code: 1, want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := r.exitCode(tt.code, tt.threshold); got != tt.want {
+			t.Errorf("exitCode(%d, %q) = %d, want %d", tt.code, tt.threshold, got, tt.want)
+		}
+	}
+}
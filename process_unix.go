@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// interruptOnCancel interrupts cmd when its context is cancelled, so terraform can release
+// state locks. Like tfexec, cmd gets its own process group, so a Ctrl-C in the terminal
+// does not interrupt it twice.
+func interruptOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+import (
+	"os/exec"
+)
+
+// interruptOnCancel keeps the default of killing cmd, Windows does not support sending interrupts
+func interruptOnCancel(cmd *exec.Cmd) {}
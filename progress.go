@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// Number of output lines kept for clients connecting to /api/progress
+const progressLines = 1000
+
+type ProgressStatus string
+
+const (
+	ProgressRunning ProgressStatus = "running"
+	ProgressDone    ProgressStatus = "done"
+	ProgressFailed  ProgressStatus = "failed"
+)
+
+// progress collects the log and terraform output while the assets are generated.
+// It keeps the last progressLines lines in a ring buffer and notifies waiting clients.
+type progress struct {
+	mu      sync.Mutex
+	lines   []string
+	total   int // Lines written so far, the index of the next line
+	partial string
+	status  ProgressStatus
+	err     string
+	changed chan struct{}
+}
+
+func newProgress() *progress {
+	return &progress{
+		lines:   make([]string, progressLines),
+		status:  ProgressRunning,
+		changed: make(chan struct{}),
+	}
+}
+
+// Write adds the complete lines of b, so terraform output and the log can be written to progress
+func (p *progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	text := p.partial + string(b)
+	lines := strings.Split(text, "\n")
+	p.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		p.lines[p.total%progressLines] = strings.TrimSuffix(line, "\r")
+		p.total++
+	}
+	if len(lines) > 1 {
+		p.notify()
+	}
+
+	return len(b), nil
+}
+
+// finish ends the progress, with the error of the asset generation if any
+func (p *progress) finish(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.partial != "" {
+		p.lines[p.total%progressLines] = p.partial
+		p.total++
+		p.partial = ""
+	}
+	p.status = ProgressDone
+	if err != nil {
+		p.status = ProgressFailed
+		p.err = err.Error()
+	}
+	p.notify()
+}
+
+// notify wakes up all clients waiting for changes, p.mu must be held
+func (p *progress) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// since returns the buffered lines from index from on, the index of the next line,
+// the status and a channel that is closed on the next change
+func (p *progress) since(from int) ([]string, int, ProgressStatus, string, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Older lines were overwritten
+	if oldest := p.total - progressLines; from < oldest {
+		from = oldest
+	}
+	if from < 0 {
+		from = 0
+	}
+
+	lines := make([]string, 0, p.total-from)
+	for i := from; i < p.total; i++ {
+		lines = append(lines, p.lines[i%progressLines])
+	}
+
+	return lines, p.total, p.status, p.err, p.changed
+}
+
+// done reports whether the asset generation has finished, successfully or not
+func (p *progress) done() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status != ProgressRunning
+}
+
+// failed returns the error of the asset generation
+func (p *progress) failed() (bool, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status == ProgressFailed, p.err
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Name of the dependency lock file in the working directory
+const lockFileName = ".terraform.lock.hcl"
+
+// Versions mentioned in constraints, e.g. 4.0 in ~> 4.0
+var constraintVersion = regexp.MustCompile(`\d+(\.\d+)*`)
+
+// ProviderInventory lists the providers of the configuration at /api/providers
+type ProviderInventory struct {
+	LockFile  string           `json:"lock_file,omitempty"`
+	Providers []*ProviderUsage `json:"providers"`
+}
+
+// ProviderUsage is a provider with its constraints, locked version and resources
+type ProviderUsage struct {
+	Source string `json:"source"`
+	// Version constraints by module address, "" is the root module
+	Constraints map[string]string `json:"constraints,omitempty"`
+	Locked      *LockedProvider   `json:"locked,omitempty"`
+	// Resource addresses by resource type
+	Resources map[string][]string `json:"resources,omitempty"`
+	Conflicts []string            `json:"conflicts,omitempty"`
+}
+
+// LockedProvider is a provider block of .terraform.lock.hcl
+type LockedProvider struct {
+	Source      string   `json:"-" hcl:"source,label"`
+	Version     string   `json:"version" hcl:"version"`
+	Constraints string   `json:"constraints,omitempty" hcl:"constraints,optional"`
+	Hashes      []string `json:"hashes,omitempty" hcl:"hashes,optional"`
+}
+
+type lockFile struct {
+	Providers []*LockedProvider `hcl:"provider,block"`
+	Remain    hcl.Body          `hcl:",remain"`
+}
+
+// loadLockFile reads the provider blocks of a dependency lock file by source
+func loadLockFile(path string) (map[string]*LockedProvider, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, diags := hclsyntax.ParseConfig(src, path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	lf := &lockFile{}
+	if diags := gohcl.DecodeBody(file.Body, nil, lf); diags.HasErrors() {
+		return nil, diags
+	}
+
+	locked := map[string]*LockedProvider{}
+	for _, p := range lf.Providers {
+		locked[strings.ToLower(p.Source)] = p
+	}
+	return locked, nil
+}
+
+// GenerateProviderInventory collects the required providers of all modules, the versions
+// locked in .terraform.lock.hcl and the resources using each provider
+func (r *rover) GenerateProviderInventory() error {
+	log.Println("Generating provider inventory...")
+
+	inventory := &ProviderInventory{Providers: []*ProviderUsage{}}
+	providers := map[string]*ProviderUsage{}
+	usage := func(source string) *ProviderUsage {
+		if providers[source] == nil {
+			providers[source] = &ProviderUsage{
+				Source:      source,
+				Constraints: map[string]string{},
+				Resources:   map[string][]string{},
+			}
+		}
+		return providers[source]
+	}
+
+	// Required providers of the modules loaded from the filesystem
+	configs := r.RSO.Configs
+	for _, module := range sortedKeys(configs) {
+		if configs[module].Module == nil {
+			continue
+		}
+		requirements := configs[module].Module.RequiredProviders
+		for _, name := range sortedKeys(requirements) {
+			p := usage(r.providerSource(name, requirements[name].Source))
+			if len(requirements[name].VersionConstraints) > 0 {
+				p.Constraints[module] = strings.Join(requirements[name].VersionConstraints, ", ")
+			}
+		}
+	}
+
+	// Resources by the provider of their config, e.g. module.network:aws
+	for _, id := range sortedKeys(configs) {
+		rc := configs[id].ResourceConfig
+		if rc == nil || rc.Type == "" {
+			continue
+		}
+		module := ""
+		if rc.Address != "" && id != rc.Address {
+			module = strings.TrimSuffix(id, "."+rc.Address)
+		}
+		name := providerName(rc.ProviderConfigKey)
+		if name == "" {
+			name, _, _ = strings.Cut(rc.Type, "_")
+		}
+		source := ""
+		if configs[module] != nil && configs[module].Module != nil && configs[module].Module.RequiredProviders[name] != nil {
+			source = configs[module].Module.RequiredProviders[name].Source
+		}
+		p := usage(r.providerSource(name, source))
+		p.Resources[rc.Type] = append(p.Resources[rc.Type], id)
+	}
+
+	lockPath := filepath.Join(r.WorkingDir, lockFileName)
+	locked, err := loadLockFile(lockPath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Unable to read %s: %s", lockFileName, err)
+	}
+	if locked != nil {
+		inventory.LockFile = lockPath
+	}
+
+	// Lock file sources can use another registry host than the binary, e.g. after switching to OpenTofu
+	byType := map[string]*ProviderUsage{}
+	for _, source := range sortedKeys(providers) {
+		if key := providerTypeKey(source); byType[key] == nil {
+			byType[key] = providers[source]
+		}
+	}
+	for _, source := range sortedKeys(locked) {
+		p := providers[source]
+		if p == nil {
+			p = byType[providerTypeKey(source)]
+		}
+		if p == nil {
+			p = usage(source)
+		}
+		p.Locked = locked[source]
+	}
+
+	for _, source := range sortedKeys(providers) {
+		p := providers[source]
+		if p.Locked == nil && locked != nil {
+			p.Conflicts = append(p.Conflicts, fmt.Sprintf("not locked in %s", lockFileName))
+		}
+		p.Conflicts = append(p.Conflicts, constraintConflicts(p.Constraints, p.Locked)...)
+		inventory.Providers = append(inventory.Providers, p)
+	}
+
+	r.Providers = inventory
+	return nil
+}
+
+// providerSource returns the fully qualified source of a provider, e.g. registry.terraform.io/hashicorp/aws for aws
+func (r *rover) providerSource(name string, source string) string {
+	if source == "" {
+		source = name
+	}
+	host := "registry.terraform.io"
+	if r.Meta.Flavor == FlavorOpenTofu {
+		host = "registry.opentofu.org"
+	}
+
+	source = strings.ToLower(source)
+	switch strings.Count(source, "/") {
+	case 0:
+		return fmt.Sprintf("%s/hashicorp/%s", host, source)
+	case 1:
+		return fmt.Sprintf("%s/%s", host, source)
+	}
+	return source
+}
+
+// providerTypeKey returns namespace/type of a provider source
+func providerTypeKey(source string) string {
+	parts := strings.Split(source, "/")
+	if len(parts) < 2 {
+		return source
+	}
+	return strings.Join(parts[len(parts)-2:], "/")
+}
+
+// constraintConflicts checks that the locked version matches the constraints of every module
+// and that the constraints of the modules can be met together. The latter is checked with the
+// versions mentioned in the constraints, which covers most constraints used in practice.
+func constraintConflicts(constraints map[string]string, locked *LockedProvider) []string {
+	conflicts := []string{}
+	parsed := map[string]version.Constraints{}
+	candidates := []*version.Version{}
+
+	for _, module := range sortedKeys(constraints) {
+		c, err := version.NewConstraint(constraints[module])
+		if err != nil {
+			conflicts = append(conflicts, fmt.Sprintf("%s: invalid constraint %q", moduleLabel(module), constraints[module]))
+			continue
+		}
+		parsed[module] = c
+
+		for _, s := range constraintVersion.FindAllString(constraints[module], -1) {
+			v, err := version.NewVersion(s)
+			if err != nil {
+				continue
+			}
+			segments := v.Segments()
+			next, _ := version.NewVersion(fmt.Sprintf("%d.%d.%d", segments[0], segments[1], segments[2]+1))
+			candidates = append(candidates, v, next)
+		}
+	}
+
+	if locked != nil {
+		if v, err := version.NewVersion(locked.Version); err == nil {
+			candidates = append(candidates, v)
+			for _, module := range sortedKeys(parsed) {
+				if !parsed[module].Check(v) {
+					conflicts = append(conflicts, fmt.Sprintf("%s: locked version %s does not match %q", moduleLabel(module), locked.Version, constraints[module]))
+				}
+			}
+		}
+	}
+
+	if len(parsed) < 2 {
+		return conflicts
+	}
+	for _, v := range candidates {
+		matchesAll := true
+		for _, c := range parsed {
+			if !c.Check(v) {
+				matchesAll = false
+				break
+			}
+		}
+		if matchesAll {
+			return conflicts
+		}
+	}
+
+	modules := []string{}
+	for _, module := range sortedKeys(parsed) {
+		modules = append(modules, fmt.Sprintf("%s %q", moduleLabel(module), constraints[module]))
+	}
+	return append(conflicts, fmt.Sprintf("no version matches the constraints of %s", strings.Join(modules, ", ")))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestConstraintConflicts(t *testing.T) {
+	tests := []struct {
+		name        string
+		constraints map[string]string
+		locked      string
+		want        []string
+	}{
+		{
+			name:        "compatible",
+			constraints: map[string]string{"": ">= 4.0", "module.vpc": "~> 4.2"},
+			locked:      "4.67.0",
+			want:        []string{},
+		},
+		{
+			name:        "locked outside constraint",
+			constraints: map[string]string{"": ">= 4.0", "module.vpc": "~> 4.2.0"},
+			locked:      "4.67.0",
+			want:        []string{`module.vpc: locked version 4.67.0 does not match "~> 4.2.0"`},
+		},
+		{
+			name:        "disjoint constraints",
+			constraints: map[string]string{"": "~> 3.0", "module.vpc": ">= 4.0"},
+			want:        []string{`no version matches the constraints of (root module) "~> 3.0", module.vpc ">= 4.0"`},
+		},
+		{
+			// Only versions between the mentioned ones match both
+			name:        "overlap between mentioned versions",
+			constraints: map[string]string{"module.a": "> 1.2.0, != 1.2.1", "module.b": "< 1.3.0"},
+			want:        []string{},
+		},
+		{
+			name:        "invalid constraint",
+			constraints: map[string]string{"": ">= 4.0", "module.vpc": "about 4"},
+			locked:      "4.67.0",
+			want:        []string{`module.vpc: invalid constraint "about 4"`},
+		},
+		{
+			name:        "single constraint",
+			constraints: map[string]string{"": "< 1.0"},
+			locked:      "1.2.0",
+			want:        []string{`(root module): locked version 1.2.0 does not match "< 1.0"`},
+		},
+	}
+
+	for _, tt := range tests {
+		var locked *LockedProvider
+		if tt.locked != "" {
+			locked = &LockedProvider{Source: "registry.terraform.io/hashicorp/aws", Version: tt.locked}
+		}
+		if got := constraintConflicts(tt.constraints, locked); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: constraintConflicts = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var reportTemplates embed.FS
+
+// Report is the data passed to the report templates
+type Report struct {
+	Name           string
+	Actions        []Action
+	Summary        *Summary
+	Modules        []ReportModule
+	Replaced       []ReportReplacement
+	NewOutputs     []string
+	RemovedOutputs []string
+	Mermaid        string
+}
+
+// ReportModule lists the changes of a single module
+type ReportModule struct {
+	Module  string
+	Totals  map[Action]int
+	Changes []SummaryChange
+}
+
+// ReportReplacement is a replaced resource and the attribute paths that forced it
+type ReportReplacement struct {
+	Address      string
+	ReplacePaths []string
+}
+
+var reportFuncs = map[string]interface{}{
+	"symbol":      actionSymbol,
+	"moduleLabel": moduleLabel,
+}
+
+// GenerateReport collects the report data from the RSO, Map and Graph
+func (r *rover) GenerateReport() *Report {
+	summary := r.GenerateSummary()
+
+	report := &Report{
+		Name:           r.Name,
+		Actions:        summaryActions,
+		Summary:        summary,
+		Modules:        []ReportModule{},
+		Replaced:       []ReportReplacement{},
+		NewOutputs:     []string{},
+		RemovedOutputs: []string{},
+	}
+
+	for _, c := range summary.Changes {
+		if len(report.Modules) == 0 || report.Modules[len(report.Modules)-1].Module != c.Module {
+			report.Modules = append(report.Modules, ReportModule{
+				Module: c.Module,
+				Totals: map[Action]int{},
+			})
+		}
+		m := &report.Modules[len(report.Modules)-1]
+		m.Totals[c.Action]++
+		m.Changes = append(m.Changes, c)
+
+		if c.Action == ActionReplace {
+			replacement := ReportReplacement{Address: c.Address}
+			if state, ok := r.RSO.States[c.Address]; ok {
+				for _, p := range state.ReplacePaths {
+					replacement.ReplacePaths = append(replacement.ReplacePaths, formatAttributePath(p))
+				}
+			}
+			report.Replaced = append(report.Replaced, replacement)
+		}
+	}
+
+	for name, output := range r.Plan.OutputChanges {
+		switch changeAction(output.Actions) {
+		case ActionCreate:
+			report.NewOutputs = append(report.NewOutputs, name)
+		case ActionDelete:
+			report.RemovedOutputs = append(report.RemovedOutputs, name)
+		}
+	}
+	sort.Strings(report.NewOutputs)
+	sort.Strings(report.RemovedOutputs)
+
+	report.Mermaid = changedMermaid(r.Graph)
+
+	return report
+}
+
+// WriteReport renders the report as markdown or html.
+// If templatePath is set, it replaces the default template of the format.
+func (r *rover) WriteReport(w io.Writer, format string, templatePath string) error {
+	name := "report.md.tmpl"
+	if format == "html" {
+		name = "report.html.tmpl"
+	} else if format != "" && format != "markdown" && format != "md" {
+		return fmt.Errorf("unknown report format %q (available: markdown, html)", format)
+	}
+
+	var src []byte
+	var err error
+	if templatePath != "" {
+		src, err = os.ReadFile(templatePath)
+	} else {
+		src, err = reportTemplates.ReadFile("templates/" + name)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read report template: %s", err)
+	}
+
+	report := r.GenerateReport()
+
+	if format == "html" {
+		tmpl, err := htmltemplate.New(name).Funcs(reportFuncs).Parse(string(src))
+		if err != nil {
+			return fmt.Errorf("unable to parse report template: %s", err)
+		}
+		return tmpl.Execute(w, report)
+	}
+
+	tmpl, err := texttemplate.New(name).Funcs(reportFuncs).Parse(string(src))
+	if err != nil {
+		return fmt.Errorf("unable to parse report template: %s", err)
+	}
+	return tmpl.Execute(w, report)
+}
+
+// formatAttributePath renders a plan attribute path, e.g. ["ingress", 0, "cidr_blocks"] as ingress[0].cidr_blocks
+func formatAttributePath(path []interface{}) string {
+	var b strings.Builder
+	for _, step := range path {
+		switch s := step.(type) {
+		case string:
+			if b.Len() > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(s)
+		case float64:
+			fmt.Fprintf(&b, "[%d]", int(s))
+		default:
+			fmt.Fprintf(&b, "[%v]", s)
+		}
+	}
+	return b.String()
+}
+
+// changedMermaid renders the changed nodes of the graph and their direct neighbors as a Mermaid flowchart
+func changedMermaid(g Graph) string {
+	nodes := map[string]Node{}
+	for _, n := range g.Nodes {
+		switch n.Data.Type {
+		case ResourceTypeFile, "basename":
+			continue
+		}
+		// Skip resource type nodes, which group the resources of a type
+		if strings.HasSuffix(n.Classes, "-type") {
+			continue
+		}
+		nodes[n.Data.ID] = n
+	}
+
+	included := map[string]bool{}
+	for id, n := range nodes {
+		if n.Data.Change != "" && n.Data.Change != string(ActionNoop) {
+			included[id] = true
+		}
+	}
+	if len(included) == 0 {
+		return ""
+	}
+
+	edges := []Edge{}
+	for _, e := range g.Edges {
+		_, sourceOk := nodes[e.Data.Source]
+		_, targetOk := nodes[e.Data.Target]
+		if !sourceOk || !targetOk {
+			continue
+		}
+		if included[e.Data.Source] || included[e.Data.Target] {
+			edges = append(edges, e)
+		}
+	}
+	for _, e := range edges {
+		included[e.Data.Source] = true
+		included[e.Data.Target] = true
+	}
+
+	ids := sortedKeys(included)
+	mermaidIds := map[string]string{}
+
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, id := range ids {
+		mermaidIds[id] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(&b, "  n%d[\"%s\"]", i, strings.ReplaceAll(id, "\"", "#quot;"))
+		if change := nodes[id].Data.Change; change != "" && change != string(ActionNoop) {
+			fmt.Fprintf(&b, ":::%s", change)
+		}
+		b.WriteString("\n")
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Data.Source != edges[j].Data.Source {
+			return edges[i].Data.Source < edges[j].Data.Source
+		}
+		return edges[i].Data.Target < edges[j].Data.Target
+	})
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  %s --> %s\n", mermaidIds[e.Data.Source], mermaidIds[e.Data.Target])
+	}
+
+	b.WriteString("  classDef create fill:#d4edda,stroke:#28a745\n")
+	b.WriteString("  classDef update fill:#fff3cd,stroke:#c69500\n")
+	b.WriteString("  classDef replace fill:#e8daf5,stroke:#8450ba\n")
+	b.WriteString("  classDef delete fill:#f8d7da,stroke:#dc3545\n")
+	b.WriteString("  classDef read fill:#d1ecf1,stroke:#17a2b8")
+
+	return b.String()
+}
@@ -9,8 +9,9 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 )
 
 // ResourcesOverview represents the root module
@@ -18,6 +19,9 @@ type ResourcesOverview struct {
 	Locations map[string]string          `json:"locations,omitempty"`
 	States    map[string]*StateOverview  `json:"states,omitempty"`
 	Configs   map[string]*ConfigOverview `json:"configs,omitempty"`
+
+	references     map[string][]string // Index from config address to references, see References
+	referencesOnce sync.Once
 }
 
 // ResourceOverview is a modified tfjson.Plan
@@ -29,6 +33,26 @@ type StateOverview struct {
 	Children  map[string]*StateOverview `json:"children,omitempty"`
 	Type      ResourceType              `json:"type,omitempty"`
 	IsParent  bool                      `json:"isparent,omitempty"`
+	// Provider source of resources, e.g. registry.terraform.io/hashicorp/aws
+	ProviderName string `json:"provider_name,omitempty"`
+	// Attribute paths that forced a replacement
+	ReplacePaths [][]interface{} `json:"replace_paths,omitempty"`
+
+	// Change of the plan before sensitive values were redacted, see plannedValues
+	planned *tfjson.Change
+}
+
+// plannedValues returns the planned values of a resource, its values before the change for deletes.
+// Policies and remote state lookups use the values of the plan, Change is redacted for the UI and API.
+func (s *StateOverview) plannedValues() interface{} {
+	change := s.Change
+	if s.planned != nil {
+		change = *s.planned
+	}
+	if change.After != nil {
+		return change.After
+	}
+	return change.Before
 }
 
 type ConfigOverview struct {
@@ -36,7 +60,9 @@ type ConfigOverview struct {
 	ModuleConfig   *tfjson.ModuleCall     `json:"module_config,omitempty"`
 	VariableConfig *tfjson.ConfigVariable `json:"variable_config,omitempty"`
 	OutputConfig   *tfjson.ConfigOutput   `json:"output_config,omitempty"`
+	LocalConfig    *Local                 `json:"local_config,omitempty"`
 	Module         *tfconfig.Module       `json:"module,omitempty"`
+	Locals         map[string]*Local      `json:"locals,omitempty"`
 }
 
 // For parsing modules.json
@@ -45,9 +71,10 @@ type ModuleLocations struct {
 }
 
 type ModuleLocation struct {
-	Key    string `json:"Key,omitempty""`
-	Source string `json:"Source,omitempty"`
-	Dir    string `json:"Dir,omitempty"`
+	Key     string `json:"Key,omitempty"`
+	Source  string `json:"Source,omitempty"`
+	Version string `json:"Version,omitempty"`
+	Dir     string `json:"Dir,omitempty"`
 }
 
 // PopulateModuleLocations Parses the modules.json file in the .terraform folder, if it exists
@@ -87,8 +114,9 @@ func (r *rover) PopulateConfigs(parent string, parentKey string, rso *ResourcesO
 	}
 
 	// Loop through variable configs
-	for variableName, variable := range config.Variables {
-		variableName = fmt.Sprintf("%svar.%s", prefix, variableName)
+	for _, name := range sortedKeys(config.Variables) {
+		variable := config.Variables[name]
+		variableName := fmt.Sprintf("%svar.%s", prefix, name)
 		if _, ok := rc[variableName]; !ok {
 			rc[variableName] = &ConfigOverview{}
 		}
@@ -96,8 +124,9 @@ func (r *rover) PopulateConfigs(parent string, parentKey string, rso *ResourcesO
 	}
 
 	// Loop through output configs
-	for outputName, output := range config.Outputs {
-		outputName = fmt.Sprintf("%soutput.%s", prefix, outputName)
+	for _, name := range sortedKeys(config.Outputs) {
+		output := config.Outputs[name]
+		outputName := fmt.Sprintf("%soutput.%s", prefix, name)
 		if _, ok := rc[outputName]; !ok {
 			rc[outputName] = &ConfigOverview{}
 		}
@@ -122,7 +151,8 @@ func (r *rover) PopulateConfigs(parent string, parentKey string, rso *ResourcesO
 	}
 
 	// Add modules
-	for moduleName, m := range config.ModuleCalls {
+	for _, moduleName := range sortedKeys(config.ModuleCalls) {
+		m := config.ModuleCalls[moduleName]
 
 		mn := fmt.Sprintf("module.%s", moduleName)
 		if prefix != "" {
@@ -154,7 +184,6 @@ func (r *rover) PopulateConfigs(parent string, parentKey string, rso *ResourcesO
 }
 
 func (r *rover) PopulateModuleState(rso *ResourcesOverview, module *tfjson.StateModule, prior bool) {
-	childIndex := regexp.MustCompile(`\[[^[\]]*\]$`)
 
 	rs := rso.States
 
@@ -260,12 +289,41 @@ func (r *rover) PopulateModuleState(rso *ResourcesOverview, module *tfjson.State
 
 }
 
+// References returns the references of all expressions of a config, ordered by attribute.
+// The index is built once on first use, the map and graph look up the config of every resource,
+// the map from several goroutines.
+func (rso *ResourcesOverview) References(configId string) []string {
+	rso.referencesOnce.Do(func() {
+		rso.references = make(map[string][]string, len(rso.Configs))
+		for id, config := range rso.Configs {
+			var expressions map[string]*tfjson.Expression
+			if config.ResourceConfig != nil {
+				expressions = config.ResourceConfig.Expressions
+			} else if config.ModuleConfig != nil {
+				expressions = config.ModuleConfig.Expressions
+			} else if config.OutputConfig != nil {
+				expressions = map[string]*tfjson.Expression{"output": config.OutputConfig.Expression}
+			} else if config.LocalConfig != nil {
+				expressions = localExpressions(config.LocalConfig)
+			}
+
+			refs := []string{}
+			for _, attr := range sortedKeys(expressions) {
+				if expressions[attr] != nil {
+					refs = append(refs, expressions[attr].References...)
+				}
+			}
+			rso.references[id] = refs
+		}
+	})
+	return rso.references[configId]
+}
+
 // GenerateResourceOverview - Overview of files and their resources
 // Groups different resource types together
 func (r *rover) GenerateResourceOverview() error {
 	log.Println("Generating resource overview...")
 
-	matchBrackets := regexp.MustCompile(`\[[^\[\]]*\]`)
 	rso := &ResourcesOverview{}
 
 	rso.Locations = make(map[string]string)
@@ -295,6 +353,50 @@ func (r *rover) GenerateResourceOverview() error {
 
 	r.PopulateConfigs("", "", rso, r.Plan.Config.RootModule)
 
+	// Parse locals of all modules loaded from the filesystem
+	// and add a config for each, like variables and outputs
+	moduleKeys := []string{}
+	for _, key := range sortedKeys(rc) {
+		if rc[key].Module != nil {
+			moduleKeys = append(moduleKeys, key)
+		}
+	}
+	// Module directories are parsed in parallel, large configurations have hundreds of modules
+	moduleLocals := make([]map[string]*Local, len(moduleKeys))
+	keys := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range keys {
+				moduleLocals[i] = loadLocals(rc[moduleKeys[i]].Module.Path)
+			}
+		}()
+	}
+	for i := range moduleKeys {
+		keys <- i
+	}
+	close(keys)
+	wg.Wait()
+
+	for i, key := range moduleKeys {
+		rc[key].Locals = moduleLocals[i]
+
+		prefix := key
+		if prefix != "" {
+			prefix = fmt.Sprintf("%s.", prefix)
+		}
+		for _, name := range sortedKeys(rc[key].Locals) {
+			l := rc[key].Locals[name]
+			localName := fmt.Sprintf("%slocal.%s", prefix, name)
+			if _, ok := rc[localName]; !ok {
+				rc[localName] = &ConfigOverview{}
+			}
+			rc[localName].LocalConfig = l
+		}
+	}
+
 	// Populate prior state
 	if r.Plan.PriorState != nil {
 		if r.Plan.PriorState.Values != nil {
@@ -324,7 +426,8 @@ func (r *rover) GenerateResourceOverview() error {
 	//reIsChild := regexp.MustCompile(`^\w+\.[\w-]+[\.\[]`)
 
 	// Loop through output changes
-	for outputName, output := range r.Plan.OutputChanges {
+	for _, outputName := range sortedKeys(r.Plan.OutputChanges) {
+		output := r.Plan.OutputChanges[outputName]
 		if _, ok := rs[outputName]; !ok {
 			rs[outputName] = &StateOverview{}
 		}
@@ -333,12 +436,12 @@ func (r *rover) GenerateResourceOverview() error {
 		if !r.ShowSensitive {
 			if output.BeforeSensitive != nil {
 				if output.BeforeSensitive.(bool) {
-					output.Before = "Sensitive Value"
+					output.Before = sensitiveValue
 				}
 			}
 			if output.AfterSensitive != nil {
 				if output.AfterSensitive.(bool) {
-					output.After = "Sensitive Value"
+					output.After = sensitiveValue
 				}
 			}
 		}
@@ -373,6 +476,13 @@ func (r *rover) GenerateResourceOverview() error {
 				rs[parent].Children[id] = rs[id]
 			}
 			rs[id].Change = *resource.Change
+			rs[id].planned = resource.Change
+			rs[id].ProviderName = resource.ProviderName
+			// Sensitive values from the plan and the provider schema
+			if !r.ShowSensitive {
+				rs[id].Change = r.redactChange(resource)
+			}
+			rs[id].ReplacePaths = r.ReplacePaths[id]
 
 			// Create resource config if doesn't exist
 			if _, ok := rc[configId]; !ok {
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Replaces sensitive values unless -showSensitive is set
+const sensitiveValue = "Sensitive Value"
+
+// SchemaIndex holds the schemas of the resources and data sources of all providers by provider source and type
+type SchemaIndex struct {
+	Resources   map[schemaKey]*tfjson.Schema
+	DataSources map[schemaKey]*tfjson.Schema
+	// Provider sources by type, for lookups without a provider source
+	resourceProviders   map[string][]string
+	dataSourceProviders map[string][]string
+}
+
+// schemaKey identifies a resource type of a provider, e.g. registry.terraform.io/hashicorp/aws and aws_instance
+type schemaKey struct {
+	Provider string
+	Type     string
+}
+
+// ResourceSchema describes a resource type at /api/schema/:resource_type
+type ResourceSchema struct {
+	Type        string                      `json:"type"`
+	Mode        tfjson.ResourceMode         `json:"mode"`
+	Provider    string                      `json:"provider"`
+	Description string                      `json:"description,omitempty"`
+	Attributes  map[string]*AttributeSchema `json:"attributes"`
+}
+
+// AttributeSchema describes an attribute, attributes of nested blocks are keyed by their path, e.g. ebs_block_device.volume_size
+type AttributeSchema struct {
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Optional    bool   `json:"optional,omitempty"`
+	Computed    bool   `json:"computed,omitempty"`
+	Sensitive   bool   `json:"sensitive,omitempty"`
+	Deprecated  bool   `json:"deprecated,omitempty"`
+}
+
+// loadProviderSchemas reads the provider schemas from -providerSchemaPath or,
+// with -providerSchema, from terraform providers schema -json
+func (r *rover) loadProviderSchemas(ctx context.Context) error {
+	var schemas *tfjson.ProviderSchemas
+
+	if r.ProviderSchemaPath != "" {
+		log.Println("Loading provider schemas...")
+		content, err := os.ReadFile(r.ProviderSchemaPath)
+		if err != nil {
+			return fmt.Errorf("Unable to read provider schemas: %s", err)
+		}
+		schemas = &tfjson.ProviderSchemas{}
+		if err := json.Unmarshal(content, schemas); err != nil {
+			return fmt.Errorf("Unable to parse provider schemas: %s", err)
+		}
+	} else if r.ProviderSchema {
+		log.Println("Reading provider schemas...")
+		tf, err := r.terraform(ctx)
+		if err != nil {
+			return err
+		}
+		schemas, err = tf.ProvidersSchema(ctx)
+		if err != nil {
+			return fmt.Errorf("Unable to read provider schemas: %s", err)
+		}
+	} else {
+		return nil
+	}
+
+	r.Schemas = newSchemaIndex(schemas)
+	return nil
+}
+
+func newSchemaIndex(schemas *tfjson.ProviderSchemas) *SchemaIndex {
+	index := &SchemaIndex{
+		Resources:           map[schemaKey]*tfjson.Schema{},
+		DataSources:         map[schemaKey]*tfjson.Schema{},
+		resourceProviders:   map[string][]string{},
+		dataSourceProviders: map[string][]string{},
+	}
+
+	for _, provider := range sortedKeys(schemas.Schemas) {
+		ps := schemas.Schemas[provider]
+		for _, t := range sortedKeys(ps.ResourceSchemas) {
+			index.Resources[schemaKey{provider, t}] = ps.ResourceSchemas[t]
+			index.resourceProviders[t] = append(index.resourceProviders[t], provider)
+		}
+		for _, t := range sortedKeys(ps.DataSourceSchemas) {
+			index.DataSources[schemaKey{provider, t}] = ps.DataSourceSchemas[t]
+			index.dataSourceProviders[t] = append(index.dataSourceProviders[t], provider)
+		}
+	}
+
+	return index
+}
+
+// lookup returns the provider source and schema of a resource or data source type.
+// Provider sources are as in the plan, e.g. registry.terraform.io/hashicorp/aws. Without one,
+// or with the local name of plans before Terraform 0.13, the first provider defining the type wins.
+func (s *SchemaIndex) lookup(mode tfjson.ResourceMode, provider string, resourceType string) (string, *tfjson.Schema) {
+	if s == nil {
+		return "", nil
+	}
+	schemas, providers := s.Resources, s.resourceProviders
+	if mode == tfjson.DataResourceMode {
+		schemas, providers = s.DataSources, s.dataSourceProviders
+	}
+
+	if schema := schemas[schemaKey{provider, resourceType}]; schema != nil {
+		return provider, schema
+	}
+	if strings.Contains(provider, "/") || len(providers[resourceType]) == 0 {
+		return "", nil
+	}
+	fallback := providers[resourceType][0]
+	return fallback, schemas[schemaKey{fallback, resourceType}]
+}
+
+// block returns the schema block of a resource or data source type, nil if unknown
+func (s *SchemaIndex) block(mode tfjson.ResourceMode, provider string, resourceType string) *tfjson.SchemaBlock {
+	_, schema := s.lookup(mode, provider, resourceType)
+	if schema == nil {
+		return nil
+	}
+	return schema.Block
+}
+
+// ResourceSchema describes the attributes of a resource or data source type
+func (s *SchemaIndex) ResourceSchema(mode tfjson.ResourceMode, provider string, resourceType string) (*ResourceSchema, error) {
+	if s == nil {
+		return nil, fmt.Errorf("no provider schemas loaded, see -providerSchema and -providerSchemaPath")
+	}
+	source, schema := s.lookup(mode, provider, resourceType)
+	if schema == nil || schema.Block == nil {
+		if provider != "" {
+			return nil, fmt.Errorf("no schema for %s %s of provider %s", mode, resourceType, provider)
+		}
+		return nil, fmt.Errorf("no schema for %s %s", mode, resourceType)
+	}
+	block := schema.Block
+
+	rs := &ResourceSchema{
+		Type:        resourceType,
+		Mode:        mode,
+		Provider:    source,
+		Description: block.Description,
+		Attributes:  map[string]*AttributeSchema{},
+	}
+	addAttributeSchemas(rs.Attributes, "", block)
+
+	return rs, nil
+}
+
+func addAttributeSchemas(attributes map[string]*AttributeSchema, prefix string, block *tfjson.SchemaBlock) {
+	for name, attr := range block.Attributes {
+		as := &AttributeSchema{
+			Description: attr.Description,
+			Required:    attr.Required,
+			Optional:    attr.Optional,
+			Computed:    attr.Computed,
+			Sensitive:   attr.Sensitive,
+			Deprecated:  attr.Deprecated,
+		}
+		if attr.AttributeType != cty.NilType {
+			as.Type = attr.AttributeType.FriendlyName()
+		} else if nested := attr.AttributeNestedType; nested != nil {
+			as.Type = nestedTypeName(nested.NestingMode)
+			addAttributeSchemas(attributes, prefix+name+".", &tfjson.SchemaBlock{Attributes: nested.Attributes})
+		}
+		attributes[prefix+name] = as
+	}
+
+	for name, nested := range block.NestedBlocks {
+		if nested.Block == nil {
+			continue
+		}
+		attributes[prefix+name] = &AttributeSchema{
+			Type:        nestedTypeName(nested.NestingMode),
+			Description: nested.Block.Description,
+			Required:    nested.MinItems > 0,
+			Optional:    nested.MinItems == 0,
+			Deprecated:  nested.Block.Deprecated,
+		}
+		addAttributeSchemas(attributes, prefix+name+".", nested.Block)
+	}
+}
+
+// nestedTypeName returns the type of a nested block or attribute, e.g. list of object
+func nestedTypeName(mode tfjson.SchemaNestingMode) string {
+	switch mode {
+	case tfjson.SchemaNestingModeSingle, tfjson.SchemaNestingModeGroup, "":
+		return "object"
+	}
+	return fmt.Sprintf("%s of object", mode)
+}
+
+// redactChange returns a copy of a resource change with the values marked sensitive
+// in the plan (before_sensitive, after_sensitive) or in the schema of its provider replaced
+func (r *rover) redactChange(rc *tfjson.ResourceChange) tfjson.Change {
+	change := *rc.Change
+	block := r.Schemas.block(rc.Mode, rc.ProviderName, rc.Type)
+
+	change.Before = redactSchema(redactMarked(change.Before, change.BeforeSensitive), block)
+	change.After = redactSchema(redactMarked(change.After, change.AfterSensitive), block)
+
+	return change
+}
+
+// redactMarked replaces the values marked true in marks, which has the structure of value
+func redactMarked(value interface{}, marks interface{}) interface{} {
+	switch m := marks.(type) {
+	case bool:
+		if m && value != nil {
+			return sensitiveValue
+		}
+	case map[string]interface{}:
+		if obj, ok := value.(map[string]interface{}); ok {
+			redacted := make(map[string]interface{}, len(obj))
+			for k, v := range obj {
+				redacted[k] = redactMarked(v, m[k])
+			}
+			return redacted
+		}
+	case []interface{}:
+		if list, ok := value.([]interface{}); ok {
+			redacted := make([]interface{}, len(list))
+			for i, v := range list {
+				if i < len(m) {
+					v = redactMarked(v, m[i])
+				}
+				redacted[i] = v
+			}
+			return redacted
+		}
+	}
+	return value
+}
+
+// redactSchema replaces the values of attributes that are sensitive in the schema block
+func redactSchema(value interface{}, block *tfjson.SchemaBlock) interface{} {
+	obj, ok := value.(map[string]interface{})
+	if !ok || block == nil {
+		return value
+	}
+
+	redacted := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		redacted[k] = v
+		if v == nil || v == sensitiveValue {
+			continue
+		}
+		if attr := block.Attributes[k]; attr != nil {
+			if attr.Sensitive {
+				redacted[k] = sensitiveValue
+			} else if nested := attr.AttributeNestedType; nested != nil {
+				redacted[k] = redactNested(v, nested.NestingMode, &tfjson.SchemaBlock{Attributes: nested.Attributes})
+			}
+		} else if nested := block.NestedBlocks[k]; nested != nil {
+			redacted[k] = redactNested(v, nested.NestingMode, nested.Block)
+		}
+	}
+	return redacted
+}
+
+// redactNested redacts each object of a nested block or attribute
+func redactNested(value interface{}, mode tfjson.SchemaNestingMode, block *tfjson.SchemaBlock) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, e := range v {
+			redacted[i] = redactSchema(e, block)
+		}
+		return redacted
+	case map[string]interface{}:
+		if mode != tfjson.SchemaNestingModeMap {
+			return redactSchema(v, block)
+		}
+		redacted := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			redacted[k] = redactSchema(e, block)
+		}
+		return redacted
+	}
+	return value
+}
+
+// schemaMode returns the resource mode of the query parameter mode, managed unless mode=data
+func schemaMode(mode string) (tfjson.ResourceMode, error) {
+	switch strings.ToLower(mode) {
+	case "", "managed", "resource":
+		return tfjson.ManagedResourceMode, nil
+	case "data":
+		return tfjson.DataResourceMode, nil
+	}
+	return "", fmt.Errorf("invalid mode %q (available: managed, data)", mode)
+}
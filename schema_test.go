@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// Two providers define aws_instance, only the fork marks user_data sensitive
+const forkedSchemas = `{
+  "format_version": "1.0",
+  "provider_schemas": {
+    "registry.terraform.io/acme/aws": {
+      "resource_schemas": {
+        "aws_instance": {"version": 0, "block": {"attributes": {
+          "ami": {"type": "string", "required": true},
+          "user_data": {"type": "string", "optional": true, "sensitive": true}
+        }}}
+      }
+    },
+    "registry.terraform.io/hashicorp/aws": {
+      "resource_schemas": {
+        "aws_instance": {"version": 0, "block": {"attributes": {
+          "ami": {"type": "string", "required": true},
+          "user_data": {"type": "string", "optional": true}
+        }}}
+      }
+    }
+  }
+}`
+
+func TestSchemaIndexLookup(t *testing.T) {
+	schemas := &tfjson.ProviderSchemas{}
+	if err := json.Unmarshal([]byte(forkedSchemas), schemas); err != nil {
+		t.Fatal(err)
+	}
+	index := newSchemaIndex(schemas)
+
+	tests := []struct {
+		provider  string
+		want      string
+		sensitive bool
+	}{
+		{provider: "registry.terraform.io/hashicorp/aws", want: "registry.terraform.io/hashicorp/aws"},
+		{provider: "registry.terraform.io/acme/aws", want: "registry.terraform.io/acme/aws", sensitive: true},
+		// Plans before Terraform 0.13 and /api/schema without ?provider fall back to the first provider
+		{provider: "aws", want: "registry.terraform.io/acme/aws", sensitive: true},
+		{provider: "", want: "registry.terraform.io/acme/aws", sensitive: true},
+		{provider: "registry.terraform.io/other/aws", want: ""},
+	}
+
+	for _, tt := range tests {
+		rs, err := index.ResourceSchema(tfjson.ManagedResourceMode, tt.provider, "aws_instance")
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ResourceSchema(%q) = %s, want an error", tt.provider, rs.Provider)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ResourceSchema(%q): %s", tt.provider, err)
+		}
+		if rs.Provider != tt.want || rs.Attributes["user_data"].Sensitive != tt.sensitive {
+			t.Errorf("ResourceSchema(%q) = %s, sensitive %v, want %s, sensitive %v",
+				tt.provider, rs.Provider, rs.Attributes["user_data"].Sensitive, tt.want, tt.sensitive)
+		}
+	}
+
+	r := &rover{Schemas: index}
+	rc := &tfjson.ResourceChange{
+		Mode:         tfjson.ManagedResourceMode,
+		Type:         "aws_instance",
+		ProviderName: "registry.terraform.io/hashicorp/aws",
+		Change: &tfjson.Change{
+			After: map[string]interface{}{"ami": "ami-123", "user_data": "echo"},
+		},
+	}
+	if after := r.redactChange(rc).After.(map[string]interface{}); after["user_data"] != "echo" {
+		t.Errorf("user_data of hashicorp/aws = %v, want echo", after["user_data"])
+	}
+	rc.ProviderName = "registry.terraform.io/acme/aws"
+	if after := r.redactChange(rc).After.(map[string]interface{}); after["user_data"] != sensitiveValue {
+		t.Errorf("user_data of acme/aws = %v, want %s", after["user_data"], sensitiveValue)
+	}
+}
+
+func TestRedactMarked(t *testing.T) {
+	value := map[string]interface{}{
+		"password": "secret",
+		"tags":     map[string]interface{}{"owner": "ops", "token": "abc"},
+		"ports":    []interface{}{80.0, 443.0},
+		"name":     nil,
+	}
+	marks := map[string]interface{}{
+		"password": true,
+		"tags":     map[string]interface{}{"token": true},
+		"ports":    []interface{}{false, true},
+		"name":     true,
+	}
+
+	got, _ := json.Marshal(redactMarked(value, marks))
+	want := `{"name":null,"password":"Sensitive Value","ports":[80,"Sensitive Value"],"tags":{"owner":"ops","token":"Sensitive Value"}}`
+	if string(got) != want {
+		t.Errorf("redactMarked = %s, want %s", got, want)
+	}
+}
+
+func TestPoliciesSeePlannedValues(t *testing.T) {
+	schemas := &tfjson.ProviderSchemas{}
+	err := json.Unmarshal([]byte(`{"format_version": "1.0", "provider_schemas": {"registry.terraform.io/hashicorp/random": {
+	  "resource_schemas": {"random_pet": {"version": 0, "block": {"attributes": {"length": {"type": "number", "optional": true, "sensitive": true}}}}}
+	}}}`), schemas)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &rover{
+		WorkingDir:   "example/random-test",
+		PlanJSONPath: "testdata/random-test/plan.json",
+		Schemas:      newSchemaIndex(schemas),
+	}
+	if err := r.getPlan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, generate := range []func() error{r.GenerateResourceOverview, r.GenerateMap, r.GenerateGraph} {
+		if err := generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	equals := "2"
+	r.EvaluatePolicies([]*PolicyRule{{
+		ID:         "short-pet",
+		Severity:   SeverityLow,
+		Address:    "random_pet.dog",
+		Attributes: []*AttributeMatch{{Path: "length", Equals: &equals}},
+	}})
+
+	// The API gets the redacted value, policies the planned one
+	if after := r.RSO.States["random_pet.dog"].Change.After.(map[string]interface{}); after["length"] != sensitiveValue {
+		t.Errorf("length in rso = %v, want %s", after["length"], sensitiveValue)
+	}
+	if len(r.Findings) != 1 || r.Findings[0].Address != "random_pet.dog" {
+		t.Errorf("findings = %+v, want short-pet on random_pet.dog", r.Findings)
+	}
+}
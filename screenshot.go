@@ -3,88 +3,139 @@ package main
 import (
 	"context"
 	"fmt"
-	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
-	"io"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
 
-func screenshot(addr string) {
-	// Chromedp-Kontext erstellen (Browser-Interaktionen)
-	ctx, cancel := chromedp.NewContext(context.Background())
-	defer cancel()
-
-	// Timeout-Kontext als Sicherheitsnetz
-	ctx, cancel = context.WithTimeout(ctx, 60*time.Second)
-	defer cancel()
-
-	// Die URL angeben, die die Screenshot-Interaktion steuert
-	url := fmt.Sprintf("http://%s", addr)
+// ImageOptions steuert die Bilderzeugung mit -genImage
+type ImageOptions struct {
+	Path      string // Zieldatei, bei PerModule mit -<Modul> vor der Endung
+	Format    string // svg, png oder pdf
+	Width     int64
+	Height    int64
+	Scale     float64
+	Timeout   time.Duration
+	Query     url.Values // Filter für die UI, z.B. changesOnly=true oder module=network
+	PerModule bool       // Ein Bild je Modul der obersten Ebene
+}
 
-	// Wird verwendet, um den Dateinamen des Downloads zu erfassen
-	var downloadGUID string
+// generateImages erzeugt das Bild des Graphen oder, bei PerModule, ein Bild je Modul der obersten Ebene
+func (r *rover) generateImages(ctx context.Context, addr string) error {
+	opts := r.Image
+	if !opts.PerModule {
+		return screenshot(ctx, addr, opts)
+	}
 
-	// Kanal zur Überwachung des Download-Fortschritts
-	downloadComplete := make(chan bool)
-	chromedp.ListenTarget(ctx, func(v interface{}) {
-		if ev, ok := v.(*browser.EventDownloadProgress); ok {
-			if ev.State == browser.DownloadProgressStateCompleted {
-				downloadGUID = ev.GUID
-				close(downloadComplete)
+	// Module der obersten Ebene liegen unter ihren Dateien, z.B. main.tf -> module.network
+	modules := []string{}
+	for _, name := range sortedKeys(r.Map.Root) {
+		res := r.Map.Root[name]
+		children := map[string]*Resource{name: res}
+		if res.Type == ResourceTypeFile {
+			children = res.Children
+		}
+		for _, id := range sortedKeys(children) {
+			if children[id].Type == ResourceTypeModule {
+				modules = append(modules, strings.TrimPrefix(id, "module."))
 			}
 		}
-	})
-
-	// Chromedp-Tasks ausführen: Navigation, Warten und Interaktion
-	if err := chromedp.Run(ctx, chromedp.Tasks{
-		browser.SetDownloadBehavior(browser.SetDownloadBehaviorBehaviorAllowAndName).
-			WithDownloadPath(os.TempDir()).
-			WithEventsEnabled(true),
-
-		chromedp.Navigate(url),                             // URL aufrufen
-		chromedp.WaitVisible(`#cytoscape-div`),             // Warten, bis das Element sichtbar ist
-		chromedp.Click(`#saveGraph`, chromedp.NodeVisible), // "Save Graph"-Button klicken
-	}); err != nil && !strings.Contains(err.Error(), "net::ERR_ABORTED") {
-		// Ignoriert net::ERR_ABORTED, da Downloads diesen Fehler manchmal auslösen
-		log.Fatal(err)
+	}
+	sort.Strings(modules)
+	if len(modules) == 0 {
+		return fmt.Errorf("No modules found for -imagePerModule")
 	}
 
-	// Blockiert, bis der Download abgeschlossen ist
-	<-downloadComplete
+	ext := filepath.Ext(opts.Path)
+	base := strings.TrimSuffix(opts.Path, ext)
+	for _, module := range modules {
+		moduleOpts := opts
+		moduleOpts.Path = fmt.Sprintf("%s-%s%s", base, module, ext)
+		moduleOpts.Query = url.Values{}
+		for k, v := range opts.Query {
+			moduleOpts.Query[k] = v
+		}
+		moduleOpts.Query.Set("module", module)
 
-	// Download-Datei verschieben
-	e := moveFile(fmt.Sprintf("%v/%v", os.TempDir(), downloadGUID), "./rover.svg")
-	if e != nil {
-		log.Fatal(e)
+		if err := screenshot(ctx, addr, moduleOpts); err != nil {
+			return fmt.Errorf("module %s: %s", module, err)
+		}
 	}
 
-	log.Println("Image generation complete.")
+	return nil
 }
 
-// Funktion zum Verschieben von Dateien, um plattformspezifische Probleme (z.B. Docker) zu vermeiden
-func moveFile(sourcePath, destPath string) error {
-	inputFile, err := os.Open(sourcePath)
-	if err != nil {
-		return fmt.Errorf("Couldn't open source file: %s", err)
+func screenshot(ctx context.Context, addr string, opts ImageOptions) error {
+	// Chromedp-Kontext erstellen (Browser-Interaktionen)
+	ctx, cancel := chromedp.NewContext(ctx)
+	defer cancel()
+
+	// Timeout-Kontext als Sicherheitsnetz
+	ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	// Die URL angeben, die Filter werden von der UI als Query-Parameter gelesen
+	u := fmt.Sprintf("http://%s", addr)
+	if len(opts.Query) > 0 {
+		u = fmt.Sprintf("%s/?%s", u, opts.Query.Encode())
 	}
-	outputFile, err := os.Create(destPath)
-	if err != nil {
-		inputFile.Close()
-		return fmt.Errorf("Couldn't open dest file: %s", err)
+
+	var content []byte
+	var capture chromedp.Action
+	switch opts.Format {
+	case "svg":
+		// SVG-Export von Cytoscape, siehe window.roverSVG in Graph.vue
+		capture = chromedp.ActionFunc(func(ctx context.Context) error {
+			var svg string
+			err := chromedp.Evaluate(fmt.Sprintf("window.roverSVG(%g)", opts.Scale), &svg).Do(ctx)
+			content = []byte(svg)
+			return err
+		})
+	case "png":
+		capture = chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			content, err = page.CaptureScreenshot().WithFormat(page.CaptureScreenshotFormatPng).Do(ctx)
+			return err
+		})
+	case "pdf":
+		// Papiergröße in Zoll entspricht dem Viewport (96 Pixel je Zoll)
+		capture = chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			content, _, err = page.PrintToPDF().
+				WithPrintBackground(true).
+				WithPaperWidth(float64(opts.Width) / 96).
+				WithPaperHeight(float64(opts.Height) / 96).
+				WithMarginTop(0).WithMarginBottom(0).WithMarginLeft(0).WithMarginRight(0).
+				WithPageRanges("1").
+				Do(ctx)
+			return err
+		})
+	default:
+		return fmt.Errorf("unknown image format %q (available: svg, png, pdf)", opts.Format)
 	}
-	defer outputFile.Close()
-	_, err = io.Copy(outputFile, inputFile)
-	inputFile.Close()
-	if err != nil {
-		return fmt.Errorf("Writing to output file failed: %s", err)
+
+	// Chromedp-Tasks ausführen: Viewport, Navigation, Warten auf das Layout und Export
+	var ready bool
+	if err := chromedp.Run(ctx, chromedp.Tasks{
+		chromedp.EmulateViewport(opts.Width, opts.Height, chromedp.EmulateScale(opts.Scale)),
+		chromedp.Navigate(u),
+		chromedp.WaitVisible(`#cytoscape-div`),
+		chromedp.Poll(`window.roverGraphReady === true`, &ready),
+		capture,
+	}); err != nil {
+		return err
 	}
-	// Original-Datei löschen
-	err = os.Remove(sourcePath)
-	if err != nil {
-		return fmt.Errorf("Failed removing original file: %s", err)
+
+	if err := os.WriteFile(opts.Path, content, 0644); err != nil {
+		return err
 	}
+
+	log.Printf("Image generation complete: %s", opts.Path)
 	return nil
 }
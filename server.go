@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"io"
 	"log"
 	"net"
+	"net/http"
+	"strings"
+	"time"
 )
 
-func (r *rover) startServer(ipPort string) error {
+func (r *rover) startServer(ctx context.Context, ipPort string) error {
 	// Erstellt eine neue Gin-Instanz
 	router := gin.Default()
 
@@ -16,22 +21,111 @@ func (r *rover) startServer(ipPort string) error {
 	// API-Gruppe unter /api/v1/ bereitstellen
 	api := router.Group("/api")
 	{
+		// Ausgabe von Rover und Terraform als Server-Sent Events, bis die Assets erzeugt sind:
+		// "line" je Zeile, zum Schluss "status" mit done oder failed und dem Fehler
+		api.GET("/progress", func(c *gin.Context) {
+			next := 0
+			c.Stream(func(w io.Writer) bool {
+				lines, total, status, errMsg, changed := r.progress.since(next)
+				for _, line := range lines {
+					c.SSEvent("line", line)
+				}
+				next = total
+
+				if status != ProgressRunning {
+					c.SSEvent("status", gin.H{"status": status, "error": errMsg})
+					return false
+				}
+
+				select {
+				case <-changed:
+					return true
+				case <-c.Request.Context().Done():
+					return false
+				}
+			})
+		})
+
+		// HCL-Block einer Adresse, z.B. /api/source/module.network.aws_vpc.main
+		api.GET("/source/*address", func(c *gin.Context) {
+			if !r.assetsReady(c) {
+				return
+			}
+			address := strings.TrimPrefix(c.Param("address"), "/")
+			if address == "" {
+				c.String(400, "Please enter an address, e.g. /api/source/aws_instance.web")
+				return
+			}
+
+			source, err := r.GetSource(address)
+			if err != nil {
+				c.JSON(404, gin.H{"error": "Source not found", "details": err.Error()})
+				return
+			}
+			c.JSON(200, source)
+		})
+
+		// Attribute der Provider-Schemas, ?mode=data für Datenquellen, ?provider=registry.terraform.io/hashicorp/aws bei mehrdeutigen Typen
+		api.GET("/schema/:resourceType", func(c *gin.Context) {
+			if !r.assetsReady(c) {
+				return
+			}
+			mode, err := schemaMode(c.Query("mode"))
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid mode", "details": err.Error()})
+				return
+			}
+			schema, err := r.Schemas.ResourceSchema(mode, c.Query("provider"), c.Param("resourceType"))
+			if err != nil {
+				c.JSON(404, gin.H{"error": "Schema not found", "details": err.Error()})
+				return
+			}
+			c.JSON(200, schema)
+		})
+
 		api.GET("/:fileType", func(c *gin.Context) {
 			fileType := c.Param("fileType")
+			if !r.assetsReady(c) {
+				return
+			}
 			var response interface{}
 			var err error
 
 			switch fileType {
 			case "plan":
 				response = r.Plan
+				// Mehrere Root-Module: Pläne nach Root-Namen
+				if r.Plans != nil {
+					response = r.Plans
+				}
 			case "rso":
 				response = r.RSO
 			case "map":
 				response = r.Map
 			case "graph":
-				response = r.Graph
+				// Filter aus den Query-Parametern, z.B. ?module=network&changedOnly=true
+				filter, err := ParseGraphFilter(c.Request.URL.Query())
+				if err != nil {
+					c.JSON(400, gin.H{"error": "Invalid graph filter", "details": err.Error()})
+					return
+				}
+				response = r.Graph.Filter(filter)
+			case "findings":
+				response = r.Findings
+			case "lint":
+				response = r.Lint
+			case "git":
+				response = r.Git
+			case "config-diff":
+				response = r.ConfigDiff
+			case "meta":
+				response = r.Meta
+			case "providers":
+				response = r.Providers
+			case "modules":
+				response = r.Modules
 			default:
-				c.String(400, "Please enter a valid file type: plan, rso, map, graph")
+				c.String(400, "Please enter a valid file type: plan, rso, map, graph, findings, lint, git, config-diff, meta, providers, modules")
 				return
 			}
 
@@ -49,16 +143,52 @@ func (r *rover) startServer(ipPort string) error {
 	// Listener erstellen
 	l, err := net.Listen("tcp", ipPort)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	// Falls Screenshot-Feature erforderlich ist
+	// Anfragen wie /api/progress enden mit dem Kontext
+	server := &http.Server{
+		Handler:     router,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(l)
+	}()
+
+	// Falls Screenshot-Feature erforderlich ist, nach dem Screenshot beenden
+	var screenshotErr error
 	if r.GenImage {
 		log.Printf("Starting screenshot generation for server on %s...", ipPort)
-		go screenshot(ipPort)
-		select {} // blockiert, um Goroutines laufen zu lassen
+		screenshotErr = r.generateImages(ctx, ipPort)
+	} else {
+		select {
+		case <-ctx.Done():
+		case err := <-served:
+			return err
+		}
+	}
+
+	// Server beenden, offene Anfragen dürfen noch abgeschlossen werden
+	log.Println("Shutting down server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
 	}
 
-	// Server starten
-	return router.RunListener(l)
+	return screenshotErr
+}
+
+// assetsReady antwortet mit 503 während die Assets erzeugt werden und mit 500, falls das fehlgeschlagen ist
+func (r *rover) assetsReady(c *gin.Context) bool {
+	if !r.progress.done() {
+		c.JSON(503, gin.H{"error": "Assets are being generated, see /api/progress", "status": ProgressRunning})
+		return false
+	}
+	if failed, errMsg := r.progress.failed(); failed {
+		c.JSON(500, gin.H{"error": "Unable to generate assets", "details": errMsg, "status": ProgressFailed})
+		return false
+	}
+	return true
 }
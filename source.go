@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Editor URL templates available by name for -editorURL
+var editorURLPresets = map[string]string{
+	"vscode":    "vscode://file/{path}:{line}",
+	"idea":      "idea://open?file={path}&line={line}",
+	"sublime":   "subl://open?url=file://{path}&line={line}",
+	"github":    "{remote}/blob/{commit}/{relpath}#L{line}",
+	"gitlab":    "{remote}/-/blob/{commit}/{relpath}#L{line}",
+	"bitbucket": "{remote}/src/{commit}/{relpath}#lines-{line}",
+}
+
+// SourceBlock is the HCL block of a resource, module call, variable, output or local
+type SourceBlock struct {
+	Address   string `json:"address"`
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Source    string `json:"source"`
+	EditorURL string `json:"editor_url,omitempty"`
+}
+
+// sourcePosition returns the absolute file path and line of the block declaring an address
+func (r *rover) sourcePosition(address string) (string, int, error) {
+	filename, line, err := configPosition(r.RSO, address)
+	// Nodes removed by config-diff are only declared in the configuration of the first ref
+	if err != nil && r.baseRSO != nil {
+		if f, l, baseErr := configPosition(r.baseRSO, address); baseErr == nil {
+			filename, line, err = f, l, nil
+		}
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return abs, line, nil
+}
+
+// configPosition returns the file and line of the block declaring an address in an overview.
+// Addresses of combined roots are namespaced, e.g. network::aws_vpc.main.
+func configPosition(rso *ResourcesOverview, address string) (string, int, error) {
+	root, address := splitNamespace(address)
+
+	prefix := moduleAddress.FindString(address)
+	key := matchBrackets.ReplaceAllString(strings.TrimSuffix(prefix, "."), "")
+	name := matchBrackets.ReplaceAllString(strings.TrimPrefix(address, prefix), "")
+	if root != "" {
+		key = namespace(root, key)
+	}
+
+	config, ok := rso.Configs[key]
+	if !ok || config.Module == nil {
+		return "", 0, fmt.Errorf("no configuration found for module %q", key)
+	}
+	module := config.Module
+
+	filename, line := "", 0
+
+	parts := strings.SplitN(name, ".", 2)
+	switch {
+	case len(parts) < 2:
+	case parts[0] == "var":
+		if v, ok := module.Variables[parts[1]]; ok {
+			filename, line = v.Pos.Filename, v.Pos.Line
+		}
+	case parts[0] == "output":
+		if o, ok := module.Outputs[parts[1]]; ok {
+			filename, line = o.Pos.Filename, o.Pos.Line
+		}
+	case parts[0] == "local":
+		if l, ok := config.Locals[parts[1]]; ok {
+			filename, line = l.Filename, l.Line
+		}
+	case parts[0] == "module":
+		if mc, ok := module.ModuleCalls[parts[1]]; ok {
+			filename, line = mc.Pos.Filename, mc.Pos.Line
+		}
+	case parts[0] == "data":
+		if d, ok := module.DataResources[name]; ok {
+			filename, line = d.Pos.Filename, d.Pos.Line
+		}
+	default:
+		if mr, ok := module.ManagedResources[name]; ok {
+			filename, line = mr.Pos.Filename, mr.Pos.Line
+		}
+	}
+
+	if filename == "" {
+		return "", 0, fmt.Errorf("%s is not declared in the configuration", address)
+	}
+
+	return filename, line, nil
+}
+
+// GetSource returns the HCL block declaring an address
+func (r *rover) GetSource(address string) (*SourceBlock, error) {
+	filename, line, err := r.sourcePosition(address)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	rng, err := blockRange(src, filename, line)
+	if err != nil {
+		return nil, err
+	}
+
+	// Include the indentation of the first line
+	start := rng.Start.Byte - (rng.Start.Column - 1)
+	if start < 0 {
+		start = 0
+	}
+
+	return &SourceBlock{
+		Address:   address,
+		Path:      filename,
+		StartLine: rng.Start.Line,
+		EndLine:   rng.End.Line,
+		Source:    string(src[start:rng.End.Byte]),
+		EditorURL: r.editorURL(filename, rng.Start.Line),
+	}, nil
+}
+
+// blockRange finds the block, or the attribute of a locals block, starting at line
+func blockRange(src []byte, filename string, line int) (hcl.Range, error) {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return hcl.Range{}, diags
+	}
+
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return hcl.Range{}, fmt.Errorf("unable to parse %s", filename)
+	}
+
+	for _, block := range body.Blocks {
+		if block.TypeRange.Start.Line == line {
+			return block.Range(), nil
+		}
+		if block.Type != "locals" {
+			continue
+		}
+		for _, attr := range block.Body.Attributes {
+			if attr.SrcRange.Start.Line == line {
+				return attr.SrcRange, nil
+			}
+		}
+	}
+
+	return hcl.Range{}, fmt.Errorf("no block found at %s:%d", filename, line)
+}
+
+// editorURL renders the -editorURL template for a file and line.
+// Returns an empty string if no template is set or git information is missing.
+func (r *rover) editorURL(path string, line int) string {
+	if r.EditorURL == "" {
+		return ""
+	}
+
+	tmpl := r.EditorURL
+	if preset, ok := editorURLPresets[tmpl]; ok {
+		tmpl = preset
+	}
+
+	values := map[string]string{
+		"{path}": filepath.ToSlash(path),
+		"{line}": strconv.Itoa(line),
+	}
+
+	if editorURLUsesGit(tmpl) {
+		// Loaded by loadEditorRepo while generating the assets, handlers only read it
+		if r.gitRepo == nil || r.gitRepo.Root == "" || r.gitRepo.Commit == "" {
+			return ""
+		}
+		if strings.Contains(tmpl, "{remote}") && r.gitRepo.Remote == "" {
+			return ""
+		}
+		values["{relpath}"] = r.gitRepo.relPath(path)
+		values["{remote}"] = r.gitRepo.Remote
+		values["{commit}"] = r.gitRepo.Commit
+	}
+
+	url := tmpl
+	for placeholder, value := range values {
+		url = strings.ReplaceAll(url, placeholder, value)
+	}
+	return url
+}
+
+// editorURLUsesGit reports whether an editor URL template has git placeholders
+func editorURLUsesGit(tmpl string) bool {
+	return strings.Contains(tmpl, "{relpath}") || strings.Contains(tmpl, "{remote}") || strings.Contains(tmpl, "{commit}")
+}
+
+// loadEditorRepo reads the git repository for the placeholders of -editorURL, once while
+// the assets are generated, so the /api/source handlers don't write to the rover concurrently
+func (r *rover) loadEditorRepo() {
+	tmpl := r.EditorURL
+	if preset, ok := editorURLPresets[tmpl]; ok {
+		tmpl = preset
+	}
+	if r.gitRepo != nil || !editorURLUsesGit(tmpl) {
+		return
+	}
+
+	repo, err := loadGitRepo(r.WorkingDir)
+	if err != nil {
+		log.Printf("Unable to read git repository for editor URLs: %s", err)
+		repo = &gitRepo{}
+	}
+	r.gitRepo = repo
+}
+
+// annotateSources sets the editor URL of every graph node declared in the configuration
+func (r *rover) annotateSources() {
+	if r.EditorURL == "" {
+		return
+	}
+	r.loadEditorRepo()
+
+	for i, n := range r.Graph.Nodes {
+		switch n.Data.Type {
+		case ResourceTypeResource, ResourceTypeData, ResourceTypeModule,
+			ResourceTypeVariable, ResourceTypeOutput, ResourceTypeLocal:
+		default:
+			continue
+		}
+
+		path, line, err := r.sourcePosition(n.Data.ID)
+		if err != nil {
+			continue
+		}
+		r.Graph.Nodes[i].Data.EditorURL = r.editorURL(path, line)
+	}
+}
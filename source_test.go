@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEditorURLConcurrent(t *testing.T) {
+	r := &rover{WorkingDir: ".", EditorURL: "file://{relpath}@{commit}#{line}"}
+	r.loadEditorRepo()
+	if r.gitRepo == nil || r.gitRepo.Root == "" {
+		t.Skip("not in a git repository")
+	}
+
+	// /api/source handlers render editor URLs concurrently
+	var wg sync.WaitGroup
+	urls := make([]string, 8)
+	for i := range urls {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			urls[i] = r.editorURL("main.go", i+1)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, url := range urls {
+		if !strings.HasPrefix(url, "file://main.go@"+r.gitRepo.Commit) {
+			t.Errorf("editorURL(main.go, %d) = %q, want file://main.go@<commit>", i+1, url)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	colorReset   = "\033[0m"
+	colorRed     = "\033[31m"
+	colorGreen   = "\033[32m"
+	colorYellow  = "\033[33m"
+	colorMagenta = "\033[35m"
+	colorCyan    = "\033[36m"
+	colorBold    = "\033[1m"
+)
+
+// Exit codes of the summary command
+const (
+	ExitNoChanges       = 0
+	ExitChanges         = 2
+	ExitProtectedDelete = 3
+)
+
+// Order in which actions are listed in totals
+var summaryActions = []Action{ActionCreate, ActionUpdate, ActionReplace, ActionDelete, ActionRead}
+
+// Summary lists the non no-op resource changes of a plan
+type Summary struct {
+	Changes   []SummaryChange           `json:"changes"`
+	Totals    map[Action]int            `json:"totals"`
+	Providers map[string]map[Action]int `json:"providers"`
+}
+
+// SummaryChange is a single resource change in a Summary
+type SummaryChange struct {
+	Address  string `json:"address"`
+	Module   string `json:"module"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	Action   Action `json:"action"`
+}
+
+// GenerateSummary collects the resource changes from the resource overview,
+// sorted by module, file and address
+func (r *rover) GenerateSummary() *Summary {
+	summary := &Summary{
+		Changes:   []SummaryChange{},
+		Totals:    map[Action]int{},
+		Providers: map[string]map[Action]int{},
+	}
+
+	locations := r.Map.Locations()
+
+	for _, rc := range r.Plan.ResourceChanges {
+		state, ok := r.RSO.States[rc.Address]
+		if !ok || rc.Change == nil {
+			continue
+		}
+
+		action := changeAction(state.Change.Actions)
+		if action == "" || action == ActionNoop {
+			continue
+		}
+
+		loc := locations[rc.Address]
+		provider := strings.TrimPrefix(strings.TrimPrefix(rc.ProviderName, "registry.terraform.io/"), "registry.opentofu.org/")
+
+		summary.Changes = append(summary.Changes, SummaryChange{
+			Address:  rc.Address,
+			Module:   rc.ModuleAddress,
+			File:     loc.File,
+			Line:     loc.Line,
+			Provider: provider,
+			Action:   action,
+		})
+
+		summary.Totals[action]++
+		if _, ok := summary.Providers[provider]; !ok {
+			summary.Providers[provider] = map[Action]int{}
+		}
+		summary.Providers[provider][action]++
+	}
+
+	sort.Slice(summary.Changes, func(i, j int) bool {
+		a, b := summary.Changes[i], summary.Changes[j]
+		if a.Module != b.Module {
+			return a.Module < b.Module
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Address < b.Address
+	})
+
+	return summary
+}
+
+// ExitCode returns the exit code of the summary command.
+// Deletes or replacements matching one of the protected patterns take precedence.
+func (s *Summary) ExitCode(detailed bool, protected []string) int {
+	for _, c := range s.Changes {
+		if c.Action != ActionDelete && c.Action != ActionReplace {
+			continue
+		}
+		for _, pattern := range protected {
+			if matchAddress(pattern, c.Address) {
+				return ExitProtectedDelete
+			}
+		}
+	}
+
+	if detailed && len(s.Changes) > 0 {
+		return ExitChanges
+	}
+
+	return ExitNoChanges
+}
+
+// matchAddress reports whether an address matches a glob in which only * is a wildcard,
+// matching any characters. Brackets match literally, e.g. aws_instance.web[0] or foo["a"].
+func matchAddress(pattern string, address string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == address
+	}
+	if !strings.HasPrefix(address, parts[0]) {
+		return false
+	}
+	address = address[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(address, part)
+		if i < 0 {
+			return false
+		}
+		address = address[i+len(part):]
+	}
+	return strings.HasSuffix(address, parts[len(parts)-1])
+}
+
+// Write renders the summary as text, markdown or json
+func (s *Summary) Write(w io.Writer, format string, color bool) error {
+	switch format {
+	case "", "text":
+		s.writeText(w, color)
+	case "markdown", "md":
+		s.writeMarkdown(w)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	default:
+		return fmt.Errorf("unknown summary format %q (available: text, markdown, json)", format)
+	}
+	return nil
+}
+
+func (s *Summary) writeText(w io.Writer, color bool) {
+	paint := func(c string, text string) string {
+		if !color {
+			return text
+		}
+		return c + text + colorReset
+	}
+
+	if len(s.Changes) == 0 {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+
+	module, file := "-", "-"
+	for _, c := range s.Changes {
+		if c.Module != module {
+			module, file = c.Module, "-"
+			fmt.Fprintln(w, paint(colorBold, moduleLabel(c.Module)))
+		}
+		if c.File != file {
+			file = c.File
+			fmt.Fprintf(w, "  %s\n", fileLabel(c.File))
+		}
+		fmt.Fprintf(w, "    %s %s\n", paint(actionColor(c.Action), fmt.Sprintf("%3s", actionSymbol(c.Action))), strings.TrimPrefix(c.Address, c.Module+"."))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Total: %s\n", s.totalsLine(s.Totals, paint))
+	for _, provider := range sortedKeys(s.Providers) {
+		fmt.Fprintf(w, "  %s: %s\n", provider, s.totalsLine(s.Providers[provider], paint))
+	}
+}
+
+func (s *Summary) totalsLine(totals map[Action]int, paint func(string, string) string) string {
+	parts := []string{}
+	for _, a := range summaryActions {
+		if totals[a] > 0 {
+			parts = append(parts, paint(actionColor(a), fmt.Sprintf("%d to %s", totals[a], a)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (s *Summary) writeMarkdown(w io.Writer) {
+	fmt.Fprintln(w, "### Rover change summary")
+	fmt.Fprintln(w)
+
+	if len(s.Changes) == 0 {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+
+	module, file := "-", "-"
+	for _, c := range s.Changes {
+		if c.Module != module {
+			module, file = c.Module, "-"
+			fmt.Fprintf(w, "- **%s**\n", moduleLabel(c.Module))
+		}
+		if c.File != file {
+			file = c.File
+			fmt.Fprintf(w, "  - %s\n", fileLabel(c.File))
+		}
+		fmt.Fprintf(w, "    - `%s` `%s`\n", actionSymbol(c.Action), strings.TrimPrefix(c.Address, c.Module+"."))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprint(w, "| Provider |")
+	for _, a := range summaryActions {
+		fmt.Fprintf(w, " %s %s |", actionSymbol(a), a)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprint(w, "| --- |")
+	for range summaryActions {
+		fmt.Fprint(w, " ---: |")
+	}
+	fmt.Fprintln(w)
+	for _, provider := range sortedKeys(s.Providers) {
+		fmt.Fprintf(w, "| %s |", provider)
+		for _, a := range summaryActions {
+			fmt.Fprintf(w, " %d |", s.Providers[provider][a])
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprint(w, "| **Total** |")
+	for _, a := range summaryActions {
+		fmt.Fprintf(w, " **%d** |", s.Totals[a])
+	}
+	fmt.Fprintln(w)
+}
+
+func moduleLabel(module string) string {
+	if module == "" {
+		return "(root module)"
+	}
+	return module
+}
+
+func fileLabel(file string) string {
+	if file == "" {
+		return DefaultFileName
+	}
+	return file
+}
+
+func actionSymbol(a Action) string {
+	switch a {
+	case ActionCreate:
+		return "+"
+	case ActionDelete:
+		return "-"
+	case ActionUpdate:
+		return "~"
+	case ActionReplace:
+		return "-/+"
+	case ActionRead:
+		return "<="
+	}
+	return " "
+}
+
+func actionColor(a Action) string {
+	switch a {
+	case ActionCreate:
+		return colorGreen
+	case ActionDelete:
+		return colorRed
+	case ActionUpdate:
+		return colorYellow
+	case ActionReplace:
+		return colorMagenta
+	case ActionRead:
+		return colorCyan
+	}
+	return colorReset
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
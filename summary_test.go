@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestMatchAddress(t *testing.T) {
+	tests := []struct {
+		pattern string
+		address string
+		want    bool
+	}{
+		{"aws_instance.web", "aws_instance.web", true},
+		{"aws_instance.web", "aws_instance.web[0]", false},
+		{"aws_instance.web[0]", "aws_instance.web[0]", true},
+		{"aws_instance.web[0]", "aws_instance.web0", false},
+		{`aws_instance.web["a"]`, `aws_instance.web["a"]`, true},
+		{"module.database.*", "module.database.aws_db_instance.main", true},
+		{"module.database.*", "module.network.aws_vpc.main", false},
+		{"module.*.aws_instance.*", `module.app["eu"].aws_instance.web[1]`, true},
+		{"*[0]", "aws_instance.web[0]", true},
+		{"*[0]", "aws_instance.web[1]", false},
+		{"*.a*a", "x.aa", true},
+		{"*.a*a", "x.a", false},
+		{"*", "", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchAddress(tt.pattern, tt.address); got != tt.want {
+			t.Errorf("matchAddress(%q, %q) = %v, want %v", tt.pattern, tt.address, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const terragruntCache = ".terragrunt-cache"
+
+const dependencyClass = "dependency"
+
+// terragruntUnit is a directory with a terragrunt.hcl
+type terragruntUnit struct {
+	root *rootModule
+	// Directory of the terragrunt.hcl
+	Dir string
+	// Directories of the units from dependency blocks, by block name
+	Dependencies map[string]string
+	// Directories from the dependencies block
+	Paths []string
+	// Input names and the dependency outputs they are set from, e.g. vpc_id: vpc.vpc_id
+	Inputs map[string][]string
+}
+
+// discoverTerragruntUnits finds all directories with a terragrunt.hcl, skipping caches and hidden directories
+func discoverTerragruntUnits(dir string) ([]string, error) {
+	units := []string{}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (d.Name() == terragruntCache || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == "terragrunt.hcl" {
+			units = append(units, filepath.Dir(path))
+		}
+		return nil
+	})
+
+	sort.Strings(units)
+	return units, err
+}
+
+// terragruntSourceDir returns the directory terragrunt runs terraform in.
+// Units with a terraform source are copied to .terragrunt-cache/<hash>/<hash>/<subdir>,
+// which contains the .terragrunt-source-version file. Units without a source run in their own directory.
+func terragruntSourceDir(unitDir string) (string, error) {
+	cache := filepath.Join(unitDir, terragruntCache)
+
+	sourceDir := ""
+	var newest int64
+	filepath.WalkDir(cache, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".terraform" {
+			return filepath.SkipDir
+		}
+		if d.Name() != ".terragrunt-source-version" {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().UnixNano() > newest {
+			newest = info.ModTime().UnixNano()
+			sourceDir = filepath.Dir(path)
+		}
+		return nil
+	})
+	if sourceDir != "" {
+		return sourceDir, nil
+	}
+
+	if tfFiles, _ := filepath.Glob(filepath.Join(unitDir, "*.tf")); len(tfFiles) > 0 {
+		return unitDir, nil
+	}
+	return "", fmt.Errorf("no terraform configuration in %s and no %s/.terragrunt-source-version, run terragrunt init first", unitDir, terragruntCache)
+}
+
+// parseTerragruntUnit reads the dependency blocks, dependencies paths and inputs of a unit.
+// Only literal paths are resolved, functions like find_in_parent_folders are skipped.
+func parseTerragruntUnit(unitDir string) (*terragruntUnit, error) {
+	filename := filepath.Join(unitDir, "terragrunt.hcl")
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	body := file.Body.(*hclsyntax.Body)
+
+	unit := &terragruntUnit{
+		Dir:          unitDir,
+		Dependencies: map[string]string{},
+		Inputs:       map[string][]string{},
+	}
+
+	resolve := func(expr hclsyntax.Expression) []string {
+		v, diags := expr.Value(nil)
+		if diags.HasErrors() || !v.IsWhollyKnown() || v.IsNull() {
+			return nil
+		}
+		paths := []string{}
+		add := func(v cty.Value) {
+			if v.Type() == cty.String {
+				p := v.AsString()
+				if !filepath.IsAbs(p) {
+					p = filepath.Join(unitDir, p)
+				}
+				paths = append(paths, filepath.Clean(p))
+			}
+		}
+		if v.Type().IsTupleType() || v.Type().IsListType() {
+			for it := v.ElementIterator(); it.Next(); {
+				_, el := it.Element()
+				add(el)
+			}
+		} else {
+			add(v)
+		}
+		return paths
+	}
+
+	for _, block := range body.Blocks {
+		switch {
+		case block.Type == "dependency" && len(block.Labels) == 1:
+			if attr, ok := block.Body.Attributes["config_path"]; ok {
+				if paths := resolve(attr.Expr); len(paths) == 1 {
+					unit.Dependencies[block.Labels[0]] = paths[0]
+				}
+			}
+		case block.Type == "dependencies":
+			if attr, ok := block.Body.Attributes["paths"]; ok {
+				unit.Paths = append(unit.Paths, resolve(attr.Expr)...)
+			}
+		}
+	}
+
+	if attr, ok := body.Attributes["inputs"]; ok {
+		if obj, ok := attr.Expr.(*hclsyntax.ObjectConsExpr); ok {
+			for _, item := range obj.Items {
+				key, diags := item.KeyExpr.Value(nil)
+				if diags.HasErrors() || key.Type() != cty.String {
+					continue
+				}
+				for _, ref := range expressionReferences(item.ValueExpr) {
+					// dependency.<name>.outputs.<output>
+					parts := strings.Split(ref, ".")
+					if len(parts) == 4 && parts[0] == "dependency" && parts[2] == "outputs" {
+						unit.Inputs[key.AsString()] = append(unit.Inputs[key.AsString()], parts[1]+"."+parts[3])
+					}
+				}
+			}
+		}
+	}
+
+	return unit, nil
+}
+
+// terragruntPlanJSON returns the plan JSON of a unit: the -terragruntPlanJSON file
+// in the unit if it exists, otherwise the output of terragrunt plan and show
+func (r *rover) terragruntPlanJSON(ctx context.Context, unitDir string, tmpDir string) (string, error) {
+	if r.TerragruntPlanJSON != "" {
+		path := filepath.Join(unitDir, r.TerragruntPlanJSON)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	planFile := filepath.Join(tmpDir, "rover.tfplan")
+	jsonFile := filepath.Join(tmpDir, "plan.json")
+
+	log.Printf("Running terragrunt plan in %s...", unitDir)
+	if _, err := r.runTerragrunt(ctx, unitDir, "plan", "-input=false", "-out="+planFile); err != nil {
+		return "", err
+	}
+
+	out, err := r.runTerragrunt(ctx, unitDir, "show", "-json", planFile)
+	if err != nil {
+		return "", err
+	}
+
+	return jsonFile, os.WriteFile(jsonFile, out, 0644)
+}
+
+func (r *rover) runTerragrunt(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, r.TerragruntPath, append(args, "--terragrunt-non-interactive")...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "TF_IN_AUTOMATION=1")
+	interruptOnCancel(cmd)
+	if r.TfPath != "" {
+		cmd.Env = append(cmd.Env, "TERRAGRUNT_TFPATH="+r.TfPath)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if r.progress != nil {
+		cmd.Stderr = io.MultiWriter(&stderr, r.progress)
+	}
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("terragrunt %s in %s: %s: %s", args[0], dir, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// generateTerragruntAssets combines the units below -terragrunt into one graph,
+// with the dependencies between units as edges
+func (r *rover) generateTerragruntAssets(ctx context.Context) error {
+	dir, err := filepath.Abs(r.TerragruntDir)
+	if err != nil {
+		return err
+	}
+
+	unitDirs, err := discoverTerragruntUnits(dir)
+	if err != nil {
+		return fmt.Errorf("Unable to discover terragrunt units in %s: %s", dir, err)
+	}
+	if len(unitDirs) == 0 {
+		return fmt.Errorf("No terragrunt.hcl found in %s", dir)
+	}
+
+	log.Printf("Found %d terragrunt units.", len(unitDirs))
+
+	tmpDir, err := os.MkdirTemp("", "rover-terragrunt")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	units := []*terragruntUnit{}
+	roots := []*rootModule{}
+	for i, unitDir := range unitDirs {
+		unit, err := parseTerragruntUnit(unitDir)
+		if err != nil {
+			return fmt.Errorf("Unable to parse %s/terragrunt.hcl: %s", unitDir, err)
+		}
+
+		unitTmp := filepath.Join(tmpDir, fmt.Sprint(i))
+		if err := os.Mkdir(unitTmp, 0755); err != nil {
+			return err
+		}
+		planJSONPath, err := r.terragruntPlanJSON(ctx, unitDir, unitTmp)
+		if err != nil {
+			return err
+		}
+
+		// The cache exists after terragrunt ran, with the downloaded source and .terraform
+		sourceDir, err := terragruntSourceDir(unitDir)
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, unitDir)
+		if err != nil || name == "." {
+			name = filepath.Base(unitDir)
+		}
+
+		root := &rootModule{
+			Name:  filepath.ToSlash(name),
+			Dir:   unitDir,
+			rover: r.rootRover(sourceDir, planJSONPath),
+		}
+		root.Backend, root.BackendConfig = loadBackend(sourceDir)
+		unit.root = root
+
+		units = append(units, unit)
+		roots = append(roots, root)
+	}
+
+	if err := r.combineRoots(ctx, roots); err != nil {
+		return err
+	}
+
+	r.linkTerragruntDependencies(units)
+
+	return nil
+}
+
+// linkTerragruntDependencies adds edges from inputs to the dependency outputs they are
+// set from, and from units to the dependencies that no input references
+func (r *rover) linkTerragruntDependencies(units []*terragruntUnit) {
+	byDir := map[string]*terragruntUnit{}
+	for _, u := range units {
+		byDir[u.Dir] = u
+	}
+
+	nodes := map[string]bool{}
+	for _, n := range r.Graph.Nodes {
+		nodes[n.Data.ID] = true
+	}
+	edges := map[string]bool{}
+
+	addEdge := func(source string, target string, gradient string) {
+		id := fmt.Sprintf("%s->%s", source, target)
+		if edges[id] || !nodes[source] || !nodes[target] {
+			return
+		}
+		edges[id] = true
+		r.Graph.Edges = append(r.Graph.Edges, Edge{
+			Data: EdgeData{
+				ID:       id,
+				Source:   source,
+				Target:   target,
+				Gradient: gradient,
+			},
+			Classes: fmt.Sprintf("edge %s", dependencyClass),
+		})
+	}
+
+	for _, u := range units {
+		linked := map[string]bool{}
+
+		for _, input := range sortedKeys(u.Inputs) {
+			for _, ref := range u.Inputs[input] {
+				name, output, _ := strings.Cut(ref, ".")
+				dep, ok := byDir[u.Dependencies[name]]
+				if !ok {
+					continue
+				}
+				addEdge(namespace(u.root.Name, "var."+input), namespace(dep.root.Name, "output."+output),
+					fmt.Sprintf("%s %s", VARIABLE_COLOR, OUTPUT_COLOR))
+				linked[dep.Dir] = true
+			}
+		}
+
+		depDirs := append([]string{}, u.Paths...)
+		for _, name := range sortedKeys(u.Dependencies) {
+			depDirs = append(depDirs, u.Dependencies[name])
+		}
+		for _, depDir := range depDirs {
+			dep, ok := byDir[depDir]
+			if !ok {
+				log.Printf("Dependency %s of %s is not a discovered unit", depDir, u.root.Name)
+				continue
+			}
+			if !linked[dep.Dir] {
+				addEdge(u.root.Name, dep.root.Name, fmt.Sprintf("%s %s", MODULE_COLOR, MODULE_COLOR))
+			}
+		}
+	}
+}
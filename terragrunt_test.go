@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// copyDir copies a testdata directory to a temporary directory, so tests can write caches into it
+func copyDir(t *testing.T, src string) string {
+	t.Helper()
+	dst := t.TempDir()
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, content, info.Mode())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dst
+}
+
+// withTerragruntStub puts the terragrunt stub of testdata/terragrunt/bin first on PATH
+func withTerragruntStub(t *testing.T, dir string) {
+	t.Helper()
+	t.Setenv("PATH", filepath.Join(dir, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestDiscoverTerragruntUnits(t *testing.T) {
+	dir := copyDir(t, "testdata/terragrunt")
+	live := filepath.Join(dir, "live")
+
+	// Caches contain copies of terragrunt.hcl, which are not units
+	cached := filepath.Join(live, "vpc", terragruntCache, "stub", "hash")
+	if err := os.MkdirAll(cached, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cached, "terragrunt.hcl"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	units, err := discoverTerragruntUnits(live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(live, "app"), filepath.Join(live, "db"), filepath.Join(live, "vpc")}
+	if !slices.Equal(units, want) {
+		t.Errorf("units = %v, want %v", units, want)
+	}
+}
+
+func TestTerragruntSourceDir(t *testing.T) {
+	dir := copyDir(t, "testdata/terragrunt")
+	withTerragruntStub(t, dir)
+	live := filepath.Join(dir, "live")
+
+	// Units with a terraform source need the cache of terragrunt init
+	if _, err := terragruntSourceDir(filepath.Join(live, "vpc")); err == nil || !strings.Contains(err.Error(), "run terragrunt init first") {
+		t.Errorf("terragruntSourceDir without cache: err = %v, want run terragrunt init first", err)
+	}
+
+	// Units without a terraform source run in their own directory
+	if got, err := terragruntSourceDir(filepath.Join(live, "db")); err != nil || got != filepath.Join(live, "db") {
+		t.Errorf("terragruntSourceDir(db) = %q, %v, want the unit directory", got, err)
+	}
+
+	r := &rover{TerragruntPath: "terragrunt"}
+	if _, err := r.runTerragrunt(context.Background(), filepath.Join(live, "vpc"), "plan", "-out="+filepath.Join(t.TempDir(), "plan")); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(live, "vpc", terragruntCache, "stub", "hash")
+	if got, err := terragruntSourceDir(filepath.Join(live, "vpc")); err != nil || got != want {
+		t.Errorf("terragruntSourceDir(vpc) = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestParseTerragruntUnit(t *testing.T) {
+	live, _ := filepath.Abs("testdata/terragrunt/live")
+
+	app, err := parseTerragruntUnit(filepath.Join(live, "app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := app.Dependencies["vpc"]; got != filepath.Join(live, "vpc") {
+		t.Errorf("dependency vpc = %q, want %q", got, filepath.Join(live, "vpc"))
+	}
+	if got := app.Inputs["vpc_id"]; !slices.Equal(got, []string{"vpc.vpc_id"}) {
+		t.Errorf("input vpc_id = %v, want [vpc.vpc_id]", got)
+	}
+
+	db, err := parseTerragruntUnit(filepath.Join(live, "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(db.Paths, []string{filepath.Join(live, "vpc")}) {
+		t.Errorf("dependencies paths = %v, want [%s]", db.Paths, filepath.Join(live, "vpc"))
+	}
+}
+
+func TestGenerateTerragruntAssets(t *testing.T) {
+	dir := copyDir(t, "testdata/terragrunt")
+	withTerragruntStub(t, dir)
+
+	r := &rover{
+		TerragruntDir:  filepath.Join(dir, "live"),
+		TerragruntPath: "terragrunt",
+	}
+	if err := r.generateTerragruntAssets(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := map[string]bool{}
+	for _, n := range r.Graph.Nodes {
+		nodes[n.Data.ID] = true
+	}
+	for _, id := range []string{"app", "db", "vpc", "app::null_resource.app", "app::var.vpc_id", "vpc::output.vpc_id", "db::null_resource.db"} {
+		if !nodes[id] {
+			t.Errorf("graph has no node %s", id)
+		}
+	}
+
+	dependencies := []string{}
+	for _, e := range r.Graph.Edges {
+		if strings.Contains(e.Classes, dependencyClass) {
+			dependencies = append(dependencies, e.Data.ID)
+		}
+	}
+	slices.Sort(dependencies)
+	want := []string{"app::var.vpc_id->vpc::output.vpc_id", "db->vpc"}
+	if !slices.Equal(dependencies, want) {
+		t.Errorf("dependency edges = %v, want %v", dependencies, want)
+	}
+}
@@ -0,0 +1,9 @@
+variable "vpc_id" {
+  type = string
+}
+
+resource "null_resource" "app" {
+  triggers = {
+    vpc_id = var.vpc_id
+  }
+}
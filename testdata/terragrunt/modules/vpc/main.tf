@@ -0,0 +1,5 @@
+resource "null_resource" "vpc" {}
+
+output "vpc_id" {
+  value = null_resource.vpc.id
+}
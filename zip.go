@@ -58,7 +58,7 @@ func (r *rover) generateZip(fe fs.FS, filename string) error {
 	if err = AddFileToZip(zipWriter, "map", r.Map); err != nil {
 		return err
 	}
-	if err = AddFileToZip(zipWriter, "graph", r.Graph); err != nil {
+	if err = AddFileToZip(zipWriter, "graph", r.Graph.Filter(r.GraphFilter)); err != nil {
 		return err
 	}
 